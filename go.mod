@@ -12,6 +12,7 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/openai/openai-go v0.1.0-alpha.61
 	golang.org/x/text v0.35.0
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/gorm v1.31.0
 )
 
@@ -19,7 +20,7 @@ require github.com/andybalholm/cascadia v1.3.3 // indirect
 
 require (
 	github.com/ClickHouse/ch-go v0.51.0 // indirect
-	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/andybalholm/brotli v1.0.4
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
 	github.com/buger/jsonparser v1.1.1 // indirect
 	github.com/bytedance/sonic v1.14.0 // indirect
@@ -39,7 +40,7 @@ require (
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
-	github.com/klauspost/compress v1.15.13 // indirect
+	github.com/klauspost/compress v1.15.13
 	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
 	github.com/larksuite/oapi-sdk-go/v3 v3.4.25
 	github.com/leodido/go-urn v1.4.0 // indirect
@@ -68,10 +69,9 @@ require (
 	golang.org/x/arch v0.20.0 // indirect
 	golang.org/x/crypto v0.49.0 // indirect
 	golang.org/x/mod v0.33.0 // indirect
-	golang.org/x/net v0.52.0 // indirect
+	golang.org/x/net v0.52.0
 	golang.org/x/sync v0.20.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
 	golang.org/x/tools v0.42.0 // indirect
 	google.golang.org/protobuf v1.36.9 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
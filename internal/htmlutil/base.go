@@ -0,0 +1,25 @@
+package htmlutil
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PageBase 按 <base> 和 pageURL 得到解析相对地址的 base，pageURL 不合法时忽略，都没有时返回 nil
+func PageBase(doc *goquery.Document, pageURL string) *url.URL {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		base = &url.URL{}
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	if base.String() == "" {
+		return nil
+	}
+	return base
+}
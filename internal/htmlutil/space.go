@@ -0,0 +1,33 @@
+// Package htmlutil khtmlextract 和 kschema 共用的网页文字、时间和地址处理
+package htmlutil
+
+// CollapseSpace 把连续的 ' '、'\t'、'\r'、'\n'、'\f' 合并成一个空格，不需要合并时直接返回 s
+func CollapseSpace(s string) string {
+	i := 0
+	for ; i < len(s); i++ {
+		if isHTMLSpace(s[i]) && (s[i] != ' ' || (i+1 < len(s) && isHTMLSpace(s[i+1]))) {
+			break
+		}
+	}
+	if i == len(s) {
+		return s
+	}
+	b := make([]byte, i, len(s))
+	copy(b, s[:i])
+	for i < len(s) {
+		if !isHTMLSpace(s[i]) {
+			b = append(b, s[i])
+			i++
+			continue
+		}
+		b = append(b, ' ')
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+	}
+	return string(b)
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f'
+}
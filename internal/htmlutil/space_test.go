@@ -0,0 +1,15 @@
+package htmlutil
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCollapseSpace(t *testing.T) {
+	re := regexp.MustCompile(`[ \t\r\n\f]+`)
+	for _, s := range []string{"", " ", "a", "a b", "  a", "a\n", "a \t\r\n\fb  c", "中文\n\n 文字", "　a  b"} {
+		if got, want := CollapseSpace(s), re.ReplaceAllString(s, " "); got != want {
+			t.Fatalf("%q: 期望 %q, 得到 %q", s, want, got)
+		}
+	}
+}
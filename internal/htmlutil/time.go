@@ -0,0 +1,104 @@
+package htmlutil
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultLocation = loadLocation("Asia/Shanghai", 8*3600)
+
+// DefaultLocation 没有时区的时间默认使用的时区 Asia/Shanghai，没有 tzdata 时为 UTC+8
+func DefaultLocation() *time.Location {
+	return defaultLocation
+}
+
+func loadLocation(name string, offset int) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		// 没有 tzdata 时使用固定时区
+		return time.FixedZone(name, offset)
+	}
+	return loc
+}
+
+// DateRe 年月日和可选的时分秒，共 6 个分组，用来拼接其它的正则
+const DateRe = `(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})\s*日?(?:\s*[T ]?\s*(\d{1,2})\s*[:：时]\s*(\d{1,2})(?:\s*[:：分]\s*(\d{1,2}))?)?`
+
+var (
+	// BareDateRe 文字中没有标签的日期
+	BareDateRe = regexp.MustCompile(DateRe)
+	tzSuffixRe = regexp.MustCompile(`(?:Z|[+-]\d{2}:?\d{2})$`)
+)
+
+// zonedLayouts 带时区的时间格式，按原时区解析
+var zonedLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ParseTime 解析常见的时间格式和 unix 时间戳，没有时区的按 loc 解析
+func ParseTime(s string, loc *time.Location) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range zonedLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil && len(s) >= 10 {
+		// unix 时间戳，毫秒或者秒
+		if len(s) >= 13 {
+			return time.UnixMilli(secs).In(loc), true
+		}
+		return time.Unix(secs, 0).In(loc), true
+	}
+	m := BareDateRe.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false
+	}
+	return DateFromMatch(m[1:], loc, tzSuffixRe.FindString(s))
+}
+
+// DateFromMatch parts 是 DateRe 的 6 个分组，tz 不为空时按它的时区解析
+func DateFromMatch(parts []string, loc *time.Location, tz string) (time.Time, bool) {
+	nums := make([]int, 6)
+	for i, p := range parts[:6] {
+		if p != "" {
+			nums[i], _ = strconv.Atoi(p)
+		}
+	}
+	if nums[1] < 1 || nums[1] > 12 || nums[2] < 1 || nums[2] > 31 || nums[3] > 23 || nums[4] > 59 || nums[5] > 59 {
+		return time.Time{}, false
+	}
+	if tz != "" {
+		loc = parseOffset(tz, loc)
+	}
+	return time.Date(nums[0], time.Month(nums[1]), nums[2], nums[3], nums[4], nums[5], 0, loc), true
+}
+
+// parseOffset 解析 Z、+08:00、+0800 这样的时区
+func parseOffset(tz string, loc *time.Location) *time.Location {
+	if tz == "Z" {
+		return time.UTC
+	}
+	digits := strings.ReplaceAll(tz[1:], ":", "")
+	if len(digits) != 4 {
+		return loc
+	}
+	h, _ := strconv.Atoi(digits[:2])
+	m, _ := strconv.Atoi(digits[2:])
+	offset := h*3600 + m*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone("", offset)
+}
@@ -0,0 +1,30 @@
+package htmlutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	cases := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2024-01-02T10:00:00Z", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"2024-01-02T10:00:00+09:00", time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)},
+		{"2024-01-02 10:00", time.Date(2024, 1, 2, 10, 0, 0, 0, loc)},
+		{"2024年1月2日 10时05分", time.Date(2024, 1, 2, 10, 5, 0, 0, loc)},
+		{"2024/01/02", time.Date(2024, 1, 2, 0, 0, 0, 0, loc)},
+		{"1704160800", time.Date(2024, 1, 2, 10, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		got, ok := ParseTime(c.s, loc)
+		if !ok || !got.Equal(c.want) {
+			t.Fatalf("%q: 期望 %v, 得到 %v %v", c.s, c.want, got, ok)
+		}
+	}
+	if _, ok := ParseTime("2024-13-40", loc); ok {
+		t.Fatal("不合法的日期不应该解析成功")
+	}
+}
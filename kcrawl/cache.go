@@ -1,11 +1,21 @@
 package kcrawl
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 type cacheData struct {
 	Request   request   `json:"request"`
 	Data      string    `json:"data"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// 响应信息，旧的缓存数据里没有这些字段
+	StatusCode int           `json:"status_code,omitempty"`
+	Status     string        `json:"status,omitempty"`
+	Header     http.Header   `json:"header,omitempty"`
+	FinalURL   string        `json:"final_url,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
 }
 
 type request struct {
@@ -13,3 +23,48 @@ type request struct {
 	URL     string `json:"url"`
 	Payload string `json:"payload"`
 }
+
+func newCacheData(res *Response) cacheData {
+	r := res.Request
+	return cacheData{
+		Data: string(res.Body),
+		Request: request{
+			Method:  r.method(),
+			URL:     r.URL,
+			Payload: r.Payload,
+		},
+		CreatedAt:  time.Now(),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+		FinalURL:   res.URL,
+		Duration:   res.Duration,
+	}
+}
+
+// response 把缓存数据还原成 Response
+func (cd *cacheData) response(r *Request) *Response {
+	res := &Response{
+		Request:    r,
+		StatusCode: cd.StatusCode,
+		Status:     cd.Status,
+		Header:     cd.Header,
+		URL:        cd.FinalURL,
+		Duration:   cd.Duration,
+		Body:       []byte(cd.Data),
+		FromCache:  true,
+		CachedAt:   cd.CreatedAt,
+	}
+	// 旧的缓存数据只保存了 body，只有 2xx 的响应才会被缓存
+	if res.StatusCode == 0 {
+		res.StatusCode = http.StatusOK
+		res.Status = "200 OK"
+	}
+	if res.Header == nil {
+		res.Header = http.Header{}
+	}
+	if res.URL == "" {
+		res.URL = r.URL
+	}
+	return res
+}
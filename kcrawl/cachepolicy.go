@@ -0,0 +1,49 @@
+package kcrawl
+
+import "time"
+
+// CachePolicy RawCacheCrawler 的缓存新鲜度策略，零值表示缓存永不过期
+type CachePolicy struct {
+	// 缓存的有效期，0 表示永不过期
+	MaxAge time.Duration
+	// 缓存过期后请求失败时，返回过期的缓存
+	StaleIfError bool
+	// 缓存过期后带上 If-None-Match / If-Modified-Since 重新请求，返回 304 时继续使用缓存
+	Revalidate bool
+	// 过期的缓存最多再保留多久，用于 StaleIfError 和 Revalidate，0 表示一直保留
+	StaleTTL time.Duration
+}
+
+// fresh 缓存是否还在有效期内
+func (p *CachePolicy) fresh(res *Response) bool {
+	if p.MaxAge <= 0 {
+		return true
+	}
+	return !res.CachedAt.IsZero() && time.Since(res.CachedAt) < p.MaxAge
+}
+
+// storeTTL 写入缓存时的过期时间，0 表示不过期
+func (p *CachePolicy) storeTTL() time.Duration {
+	if p.MaxAge <= 0 {
+		return 0
+	}
+	if p.StaleIfError || p.Revalidate {
+		if p.StaleTTL <= 0 {
+			return 0
+		}
+		return p.MaxAge + p.StaleTTL
+	}
+	return p.MaxAge
+}
+
+// conditionalHeader 根据缓存的 ETag 和 Last-Modified 生成重新验证用的 header
+func conditionalHeader(cached *Response) map[string]string {
+	header := map[string]string{}
+	if etag := cached.Header.Get("ETag"); etag != "" {
+		header["If-None-Match"] = etag
+	}
+	if lm := cached.Header.Get("Last-Modified"); lm != "" {
+		header["If-Modified-Since"] = lm
+	}
+	return header
+}
@@ -0,0 +1,95 @@
+package kcrawl
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// decodeContentEncoding 按 Content-Encoding 解压 body，多个编码按相反的顺序解
+func decodeContentEncoding(body []byte, contentEncoding string) ([]byte, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		enc := strings.ToLower(strings.TrimSpace(encodings[i]))
+		var err error
+		switch enc {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			body, err = readAllClose(gzip.NewReader(bytes.NewReader(body)))
+		case "deflate":
+			body, err = inflate(body)
+		case "br":
+			body, err = io.ReadAll(brotli.NewReader(bytes.NewReader(body)))
+		case "zstd":
+			var d *zstd.Decoder
+			d, err = zstd.NewReader(nil)
+			if err == nil {
+				body, err = d.DecodeAll(body, nil)
+				d.Close()
+			}
+		default:
+			return nil, fmt.Errorf("unsupported content encoding: %s", enc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode content encoding %s: %w", enc, err)
+		}
+	}
+	return body, nil
+}
+
+// inflate http 的 deflate 应该是 zlib 格式，但是不少网站直接返回 raw deflate，两种都试一下
+func inflate(body []byte) ([]byte, error) {
+	if data, err := readAllClose(zlib.NewReader(bytes.NewReader(body))); err == nil {
+		return data, nil
+	}
+	return readAllClose(flate.NewReader(bytes.NewReader(body)), nil)
+}
+
+func readAllClose(r io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([\w-]+)`)
+
+// toUTF8 把 body 转成 utf-8。
+// 编码的判断顺序：BOM、Content-Type 的 charset、<meta charset>；都没有声明时，合法的 utf-8 原样返回，否则当作 GB18030
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	e, name, certain := charset.DetermineEncoding(body, contentType)
+	if !certain {
+		head := body
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		name = ""
+		if m := metaCharsetRe.FindSubmatch(head); m != nil {
+			e, name = charset.Lookup(string(m[1]))
+		}
+		if e == nil || name == "" {
+			if utf8.Valid(body) {
+				return body, nil
+			}
+			// 我们抓的中文站点没有声明编码时基本都是 GBK
+			e, name = simplifiedchinese.GB18030, "gb18030"
+		}
+	}
+	if name == "utf-8" {
+		return body, nil
+	}
+	return e.NewDecoder().Bytes(body)
+}
@@ -0,0 +1,89 @@
+package kcrawl
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestRawCrawlerContentEncoding(t *testing.T) {
+	const text = "你好, kcrawl"
+	encoders := map[string]func([]byte) []byte{
+		"gzip": func(b []byte) []byte {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			w.Write(b)
+			w.Close()
+			return buf.Bytes()
+		},
+		"deflate": func(b []byte) []byte {
+			var buf bytes.Buffer
+			w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			w.Write(b)
+			w.Close()
+			return buf.Bytes()
+		},
+		"br": func(b []byte) []byte {
+			var buf bytes.Buffer
+			w := brotli.NewWriter(&buf)
+			w.Write(b)
+			w.Close()
+			return buf.Bytes()
+		},
+		"zstd": func(b []byte) []byte {
+			w, _ := zstd.NewWriter(nil)
+			defer w.Close()
+			return w.EncodeAll(b, nil)
+		},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := r.URL.Query().Get("enc")
+		w.Header().Set("Content-Encoding", enc)
+		w.Write(encoders[enc]([]byte(text)))
+	}))
+	defer ts.Close()
+
+	// 手动设置 Accept-Encoding 后 net/http 不会自动解压 gzip
+	c := NewRawCrawler(0, map[string]string{"Accept-Encoding": "gzip, deflate, br, zstd"})
+	for enc := range encoders {
+		data, err := c.Get(ts.URL + "?enc=" + enc)
+		if err != nil {
+			t.Fatalf("%s: %v", enc, err)
+		}
+		if string(data) != text {
+			t.Fatalf("%s: 期望 %q, 得到 %q", enc, text, data)
+		}
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("<html><head><meta charset=\"gbk\"></head><body>中文</body></html>")
+	gbkBody, _ := simplifiedchinese.GBK.NewEncoder().String("<body>中文</body>")
+	cases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"meta", []byte(gbk), "text/html", "<html><head><meta charset=\"gbk\"></head><body>中文</body></html>"},
+		{"content-type", []byte(gbkBody), "text/html; charset=GB2312", "<body>中文</body>"},
+		{"fallback", []byte(gbkBody), "text/html", "<body>中文</body>"},
+		{"utf-8", []byte("<p>中文</p>"), "text/html", "<p>中文</p>"},
+	}
+	for _, c := range cases {
+		got, err := toUTF8(c.body, c.contentType)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if string(got) != c.want {
+			t.Fatalf("%s: 期望 %q, 得到 %q", c.name, c.want, got)
+		}
+	}
+}
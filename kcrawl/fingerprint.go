@@ -0,0 +1,118 @@
+package kcrawl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CacheKeyer 根据请求生成缓存 key
+type CacheKeyer interface {
+	CacheKey(r *Request) string
+}
+
+// CacheKeyerFunc 把函数转换成 CacheKeyer
+type CacheKeyerFunc func(r *Request) string
+
+func (f CacheKeyerFunc) CacheKey(r *Request) string {
+	return f(r)
+}
+
+// FingerprintKeyer 请求指纹，包含 method、规范化之后的 url、指定的 header 和 body。
+// json body 会先规范化（key 排序、去掉空白）再参与计算，所以字段顺序不同的 json 是同一个 key
+type FingerprintKeyer struct {
+	// 参与计算的 header，不区分大小写
+	Headers []string
+}
+
+// NewFingerprintKeyer headers 为参与计算的 header，比如 Authorization、Accept-Language
+func NewFingerprintKeyer(headers ...string) *FingerprintKeyer {
+	return &FingerprintKeyer{Headers: headers}
+}
+
+func (k *FingerprintKeyer) CacheKey(r *Request) string {
+	h := sha256.New()
+	h.Write([]byte(strings.ToUpper(r.method())))
+	h.Write([]byte{'\n'})
+	u, err := NormalizeURL(r.URL)
+	if err != nil {
+		u = r.URL
+	}
+	h.Write([]byte(u))
+	h.Write([]byte{'\n'})
+
+	names := make([]string, 0, len(k.Headers))
+	for _, name := range k.Headers {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte(name + ":" + headerValue(r.Header, name) + "\n"))
+	}
+
+	body := sha256.Sum256(canonicalBody(r.Payload))
+	h.Write(body[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// headerValue header 名称不区分大小写
+func headerValue(header map[string]string, name string) string {
+	for k, v := range header {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// canonicalBody 合法的 json 重新序列化（map 的 key 会排序），其它原样返回
+func canonicalBody(payload string) []byte {
+	trimmed := strings.TrimSpace(payload)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return []byte(payload)
+	}
+	d := json.NewDecoder(strings.NewReader(trimmed))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.More() {
+		return []byte(payload)
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return []byte(payload)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// NormalizeURL 规范化 url：scheme 和 host 转小写，去掉默认端口和 fragment，空 path 补 /，query 参数按 key 排序
+func NormalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" && u.Host != "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		// url.Values.Encode 会按 key 排序，同一个 key 的多个值保持原来的顺序
+		q, err := url.ParseQuery(u.RawQuery)
+		if err == nil {
+			u.RawQuery = q.Encode()
+		}
+	}
+	return u.String(), nil
+}
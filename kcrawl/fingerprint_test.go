@@ -0,0 +1,35 @@
+package kcrawl
+
+import "testing"
+
+func TestFingerprintKeyer(t *testing.T) {
+	k := NewFingerprintKeyer("Authorization")
+	key := func(r Request) string { return k.CacheKey(&r) }
+
+	base := key(Request{Method: "POST", URL: "http://a.com/x?b=2&a=1", Payload: `{"b":1,"a":[1,2]}`})
+	same := []Request{
+		{Method: "post", URL: "HTTP://A.com:80/x?a=1&b=2#frag", Payload: `{ "a": [1, 2], "b": 1 }`},
+		{Method: "POST", URL: "http://a.com/x?a=1&b=2", Payload: `{"a":[1,2],"b":1}`, Header: map[string]string{"Accept-Language": "zh"}},
+	}
+	for _, r := range same {
+		if key(r) != base {
+			t.Fatalf("应该是同一个 key: %+v", r)
+		}
+	}
+
+	diff := []Request{
+		{Method: "GET", URL: "http://a.com/x?a=1&b=2", Payload: `{"a":[1,2],"b":1}`},
+		{Method: "POST", URL: "http://a.com/x?a=1&b=3", Payload: `{"a":[1,2],"b":1}`},
+		{Method: "POST", URL: "http://a.com/x?a=1&b=2", Payload: `{"a":[2,1],"b":1}`},
+		{Method: "POST", URL: "http://a.com/x?a=1&b=2", Payload: `{"a":[1,2],"b":1}`, Header: map[string]string{"authorization": "t"}},
+	}
+	for _, r := range diff {
+		if key(r) == base {
+			t.Fatalf("不应该是同一个 key: %+v", r)
+		}
+	}
+
+	if key(Request{Method: "GET", URL: "http://a.com/"}) == key(Request{Method: "POST", URL: "http://a.com/"}) {
+		t.Fatal("GET 和空 body 的 POST 不应该是同一个 key")
+	}
+}
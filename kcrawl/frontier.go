@@ -0,0 +1,271 @@
+package kcrawl
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/kevin-zx/kbase/ksqlite"
+)
+
+// URLState frontier 中 url 的状态
+type URLState int
+
+const (
+	URLPending URLState = iota
+	URLInFlight
+	URLDone
+	URLFailed
+)
+
+func (s URLState) String() string {
+	switch s {
+	case URLPending:
+		return "pending"
+	case URLInFlight:
+		return "in-flight"
+	case URLDone:
+		return "done"
+	case URLFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("URLState(%d)", int(s))
+}
+
+// FrontierItem frontier 中的一个 url
+type FrontierItem struct {
+	ID  int64
+	URL string
+	// 种子的深度为 0，从某个页面发现的链接深度加 1
+	Depth int
+	// 越大越先抓取，相同时深度小的先抓取
+	Priority  int
+	State     URLState
+	Attempts  int
+	LastError string
+}
+
+// FrontierStats 各个状态的 url 数量
+type FrontierStats struct {
+	Pending  int
+	InFlight int
+	Done     int
+	Failed   int
+}
+
+// Frontier 保存在 sqlite 中的待抓取队列，url 规范化之后去重。
+// 程序中断后重新打开时，抓取中的 url 会重新变成待抓取，从中断的地方继续
+type Frontier struct {
+	db          *sql.DB
+	table       string
+	maxAttempts int
+	// 0 表示不限制
+	maxDepth int
+}
+
+type FrontierOption func(f *Frontier)
+
+// FrontierWithMaxAttempts 每个 url 最多尝试的次数，默认 3 次，超过后状态为 failed
+func FrontierWithMaxAttempts(n int) FrontierOption {
+	return func(f *Frontier) {
+		f.maxAttempts = n
+	}
+}
+
+// FrontierWithMaxDepth 超过 maxDepth 的 url 不会加入队列
+func FrontierWithMaxDepth(maxDepth int) FrontierOption {
+	return func(f *Frontier) {
+		f.maxDepth = maxDepth
+	}
+}
+
+// NewFrontier 在 dbfile 中创建或者打开名为 name 的队列，同一个文件可以保存多个队列
+func NewFrontier(dbfile string, name string, opts ...FrontierOption) (*Frontier, error) {
+	db, err := ksqlite.CreateDB(fmt.Sprintf("file:%s?cache=shared&mode=rwc&_busy_timeout=5000", dbfile))
+	if err != nil {
+		return nil, fmt.Errorf("frontier file: %s, got a error: %v", dbfile, err)
+	}
+	// sqlite 只能单写，用一个连接避免 database is locked
+	db.SetMaxOpenConns(1)
+	f := &Frontier{db: db, table: name + "_frontier", maxAttempts: 3}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err = f.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("frontier file: %s, got a error: %v", dbfile, err)
+	}
+	return f, nil
+}
+
+func (f *Frontier) init() error {
+	_, err := f.db.Exec("CREATE TABLE IF NOT EXISTS " + f.table + ` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		depth INTEGER NOT NULL DEFAULT 0,
+		priority INTEGER NOT NULL DEFAULT 0,
+		state INTEGER NOT NULL DEFAULT 0,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME,
+		updated_at DATETIME)`)
+	if err != nil {
+		return err
+	}
+	_, err = f.db.Exec("CREATE INDEX IF NOT EXISTS " + f.table + "_next ON " + f.table + " (state, priority DESC, depth, id)")
+	if err != nil {
+		return err
+	}
+	// 上次中断时还在抓取的 url 重新抓取
+	_, err = f.db.Exec("UPDATE "+f.table+" SET state = ?, updated_at = ? WHERE state = ?", URLPending, time.Now(), URLInFlight)
+	return err
+}
+
+func (f *Frontier) Close() error {
+	return f.db.Close()
+}
+
+// Push 加入一个 url，已经存在时返回 false
+func (f *Frontier) Push(rawURL string, depth int, priority int) (bool, error) {
+	n, err := f.push(f.db, rawURL, depth, priority)
+	return n > 0, err
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (f *Frontier) push(db sqlExecer, rawURL string, depth int, priority int) (int64, error) {
+	if f.maxDepth > 0 && depth > f.maxDepth {
+		return 0, nil
+	}
+	u, err := NormalizeURL(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	res, err := db.Exec("INSERT OR IGNORE INTO "+f.table+" (url, depth, priority, state, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		u, depth, priority, URLPending, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PushLinks 加入从 from 页面发现的链接，深度为 from.Depth+1，优先级和 from 相同。
+// 不是 http 和 https 的链接会被忽略，返回新加入的数量。from 为空时作为种子加入
+func (f *Frontier) PushLinks(from *FrontierItem, links []string) (int, error) {
+	depth, priority := 0, 0
+	if from != nil {
+		depth, priority = from.Depth+1, from.Priority
+	}
+	tx, err := f.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	added := 0
+	for _, link := range links {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		n, err := f.push(tx, link, depth, priority)
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+	return added, tx.Commit()
+}
+
+// Next 取出下一个待抓取的 url 并标记为抓取中，没有待抓取的 url 时返回 nil
+func (f *Frontier) Next() (*FrontierItem, error) {
+	item := &FrontierItem{}
+	err := f.db.QueryRow("UPDATE "+f.table+" SET state = ?, attempts = attempts + 1, updated_at = ?"+
+		" WHERE id = (SELECT id FROM "+f.table+" WHERE state = ? ORDER BY priority DESC, depth, id LIMIT 1)"+
+		" RETURNING id, url, depth, priority, state, attempts, last_error",
+		URLInFlight, time.Now(), URLPending,
+	).Scan(&item.ID, &item.URL, &item.Depth, &item.Priority, &item.State, &item.Attempts, &item.LastError)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Done 标记 item 抓取完成
+func (f *Frontier) Done(item *FrontierItem) error {
+	item.State = URLDone
+	item.LastError = ""
+	return f.setState(item)
+}
+
+// Fail 标记 item 抓取失败，没有超过最大尝试次数时重新变成待抓取
+func (f *Frontier) Fail(item *FrontierItem, cause error) error {
+	item.State = URLPending
+	if item.Attempts >= f.maxAttempts {
+		item.State = URLFailed
+	}
+	item.LastError = ""
+	if cause != nil {
+		item.LastError = cause.Error()
+	}
+	return f.setState(item)
+}
+
+func (f *Frontier) setState(item *FrontierItem) error {
+	_, err := f.db.Exec("UPDATE "+f.table+" SET state = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		item.State, item.LastError, time.Now(), item.ID)
+	return err
+}
+
+// Get 查询 url 的状态，不存在时返回 nil
+func (f *Frontier) Get(rawURL string) (*FrontierItem, error) {
+	u, err := NormalizeURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	item := &FrontierItem{}
+	err = f.db.QueryRow("SELECT id, url, depth, priority, state, attempts, last_error FROM "+f.table+" WHERE url = ?", u).
+		Scan(&item.ID, &item.URL, &item.Depth, &item.Priority, &item.State, &item.Attempts, &item.LastError)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Stats 各个状态的 url 数量
+func (f *Frontier) Stats() (FrontierStats, error) {
+	var stats FrontierStats
+	rows, err := f.db.Query("SELECT state, COUNT(*) FROM " + f.table + " GROUP BY state")
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state URLState
+		var n int
+		if err = rows.Scan(&state, &n); err != nil {
+			return stats, err
+		}
+		switch state {
+		case URLPending:
+			stats.Pending = n
+		case URLInFlight:
+			stats.InFlight = n
+		case URLDone:
+			stats.Done = n
+		case URLFailed:
+			stats.Failed = n
+		}
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,79 @@
+package kcrawl
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrontier(t *testing.T) {
+	dbfile := filepath.Join(t.TempDir(), "frontier.sqlite")
+	f, err := NewFrontier(dbfile, "test", FrontierWithMaxAttempts(2), FrontierWithMaxDepth(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, err := f.Push("http://a.com/?b=2&a=1#x", 0, 0); err != nil || !added {
+		t.Fatal(added, err)
+	}
+	if added, _ := f.Push("HTTP://A.com:80/?a=1&b=2", 0, 0); added {
+		t.Fatal("规范化之后相同的 url 不应该重复加入")
+	}
+	if _, err = f.Push("http://a.com/vip", 0, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := f.Next()
+	if err != nil || item.URL != "http://a.com/vip" || item.State != URLInFlight || item.Attempts != 1 {
+		t.Fatalf("优先级高的应该先出队: %+v %v", item, err)
+	}
+	n, err := f.PushLinks(item, []string{"http://a.com/1", "javascript:void(0)", "mailto:a@a.com", "http://a.com/vip"})
+	if err != nil || n != 1 {
+		t.Fatalf("应该只加入一个链接: %d %v", n, err)
+	}
+	if err = f.Done(item); err != nil {
+		t.Fatal(err)
+	}
+	// 深度超过限制的链接不加入
+	child, _ := f.Get("http://a.com/1")
+	if n, _ = f.PushLinks(child, []string{"http://a.com/2"}); n != 0 {
+		t.Fatal("超过最大深度的链接不应该加入")
+	}
+
+	// 模拟中断：取出之后没有标记就关闭
+	item, _ = f.Next()
+	if item.Priority != 10 || item.Depth != 1 {
+		t.Fatalf("链接应该继承优先级: %+v", item)
+	}
+	f.Close()
+
+	f, err = NewFrontier(dbfile, "test", FrontierWithMaxAttempts(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stats, _ := f.Stats()
+	if stats != (FrontierStats{Pending: 2, Done: 1}) {
+		t.Fatalf("重新打开后抓取中的 url 应该变成待抓取: %+v", stats)
+	}
+	item, _ = f.Next()
+	if item.URL != "http://a.com/1" || item.Attempts != 2 {
+		t.Fatalf("应该从中断的 url 继续: %+v", item)
+	}
+	if err = f.Fail(item, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := f.Get("http://a.com/1")
+	if got.State != URLFailed || got.LastError != "boom" {
+		t.Fatalf("超过最大尝试次数应该失败: %+v", got)
+	}
+	item, _ = f.Next()
+	if item.URL != "http://a.com/?a=1&b=2" {
+		t.Fatalf("%+v", item)
+	}
+	if err = f.Fail(item, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = f.Get(item.URL); got.State != URLPending {
+		t.Fatalf("没有超过最大尝试次数应该重新待抓取: %+v", got)
+	}
+}
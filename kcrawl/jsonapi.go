@@ -0,0 +1,132 @@
+package kcrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// DecodeError 响应不能解析成目标类型，Snippet 是出错位置附近的一段 body
+type DecodeError struct {
+	URL string
+	// 出错的字段路径，如 data.items.0.price，语法错误时为空
+	Field string
+	// 出错位置在 body 中的偏移
+	Offset  int64
+	Snippet string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("unmarshal error: %s and body is: %s", e.Err.Error(), e.Snippet)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// snippetRadius Snippet 在出错位置前后各保留的字节数
+const snippetRadius = 100
+
+func newDecodeError(url string, body []byte, err error) *DecodeError {
+	de := &DecodeError{URL: url, Err: err}
+	var te *json.UnmarshalTypeError
+	var se *json.SyntaxError
+	switch {
+	case errors.As(err, &te):
+		de.Field = te.Field
+		de.Offset = te.Offset
+	case errors.As(err, &se):
+		de.Offset = se.Offset
+	}
+	start := max(int(de.Offset)-snippetRadius, 0)
+	end := min(int(de.Offset)+snippetRadius, len(body))
+	if start > end {
+		start = end
+	}
+	de.Snippet = string(body[start:end])
+	return de
+}
+
+// RequestOption GetJSON 和 PostJSON 的请求选项
+type RequestOption func(r *Request)
+
+// ReqWithHeader 单次请求的 header
+func ReqWithHeader(header map[string]string) RequestOption {
+	return func(r *Request) {
+		if r.Header == nil {
+			r.Header = make(map[string]string, len(header))
+		}
+		maps.Copy(r.Header, header)
+	}
+}
+
+// ReqWithCacheKeys 指定缓存 key
+func ReqWithCacheKeys(keys ...string) RequestOption {
+	return func(r *Request) {
+		r.Keys = keys
+	}
+}
+
+// ReqWithCachePolicy 单次请求的缓存策略
+func ReqWithCachePolicy(policy CachePolicy) RequestOption {
+	return func(r *Request) {
+		r.CachePolicy = &policy
+	}
+}
+
+// GetJSON 请求 url 并把响应解析成 T，解析失败时返回 *DecodeError（可以用 errors.As 获取），失败的响应不会缓存
+func GetJSON[T any](ctx context.Context, crawler RawCacheCrawler, url string, opts ...RequestOption) (T, error) {
+	r := &Request{Method: "GET", URL: url}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return DoJSON[T](ctx, crawler, r)
+}
+
+// PostJSON 把 body 编码成 json 后 POST 到 url，并把响应解析成 Resp
+func PostJSON[Req, Resp any](ctx context.Context, crawler RawCacheCrawler, url string, body Req, opts ...RequestOption) (Resp, error) {
+	var zero Resp
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return zero, err
+	}
+	r := &Request{Method: "POST", URL: url, Payload: string(payload), Header: map[string]string{"Content-Type": "application/json"}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return DoJSON[Resp](ctx, crawler, r)
+}
+
+// DoJSON 发起 r 并把响应解析成 T。解析作为请求的校验执行，解析失败的响应不会写入缓存，
+// 也不会覆盖已有的缓存；缓存中的 body 解析失败时删除这条缓存
+func DoJSON[T any](ctx context.Context, crawler RawCacheCrawler, r *Request) (T, error) {
+	var v T
+	req := *r
+	req.Validators = append(slices.Clip(r.Validators), func(res *Response) error {
+		var t T
+		if err := json.Unmarshal(res.Body, &t); err != nil {
+			return newDecodeError(res.URL, res.Body, err)
+		}
+		return nil
+	})
+	res, err := crawler.DoResponse(ctx, &req)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(res.Body, &v)
+	if err != nil {
+		de := newDecodeError(r.URL, res.Body, err)
+		if !res.FromCache {
+			return v, de
+		}
+		if err = crawler.DeleteRequestCache(r); err != nil {
+			return v, errors.Join(de, err)
+		}
+		return v, de
+	}
+	return v, nil
+}
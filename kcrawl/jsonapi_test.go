@@ -0,0 +1,110 @@
+package kcrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetJSON(t *testing.T) {
+	type item struct {
+		Price int `json:"price"`
+	}
+	type page struct {
+		Data struct {
+			Items []item `json:"items"`
+		} `json:"data"`
+	}
+	var bad atomic.Bool
+	bad.Store(true)
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Method == "POST" {
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+			return
+		}
+		if bad.Load() {
+			w.Write([]byte(`{"data":{"items":[{"price":"12"}]}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"items":[{"price":12}]}}`))
+	}))
+	defer ts.Close()
+
+	c := NewRawCacheCrawler(newTestCache(t))
+	ctx := context.Background()
+	_, err := GetJSON[page](ctx, c, ts.URL)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("期望 DecodeError, 得到 %v", err)
+	}
+	if !strings.HasPrefix(de.Field, "data.items.") || !strings.HasSuffix(de.Field, ".price") || de.Snippet == "" {
+		t.Fatalf("DecodeError 不对: %+v", de)
+	}
+
+	// 错误的 body 不应该留在缓存中
+	bad.Store(false)
+	p, err := GetJSON[page](ctx, c, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Data.Items) != 1 || p.Data.Items[0].Price != 12 || hits.Load() != 2 {
+		t.Fatalf("结果不对: %+v, 请求 %d 次", p, hits.Load())
+	}
+	if _, err = GetJSON[page](ctx, c, ts.URL); err != nil || hits.Load() != 2 {
+		t.Fatalf("应该命中缓存: %v, 请求 %d 次", err, hits.Load())
+	}
+
+	got, err := PostJSON[item, json.RawMessage](ctx, c, ts.URL, item{Price: 3})
+	if err != nil || string(got) != `{"price":3}` {
+		t.Fatalf("PostJSON 结果不对: %s %v", got, err)
+	}
+}
+
+func TestDoJSONKeepsGoodCache(t *testing.T) {
+	var bad atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bad.Load() {
+			w.Write([]byte(`<html>验证码</html>`))
+			return
+		}
+		w.Write([]byte(`{"price":12}`))
+	}))
+	defer ts.Close()
+
+	type item struct {
+		Price int `json:"price"`
+	}
+	c := NewRawCacheCrawler(newTestCache(t))
+	ctx := context.Background()
+	// Revalidate 时过期的缓存不会被 sqlite 删除
+	expired := ReqWithCachePolicy(CachePolicy{MaxAge: time.Nanosecond, Revalidate: true})
+	if _, err := GetJSON[item](ctx, c, ts.URL, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓存过期后重新请求得到错误的 body，不应该覆盖或者删除原来的缓存
+	bad.Store(true)
+	_, err := GetJSON[item](ctx, c, ts.URL, expired)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("期望 DecodeError, 得到 %v", err)
+	}
+	body, err, ok := c.GetCache(ts.URL)
+	if err != nil || !ok || string(body) != `{"price":12}` {
+		t.Fatalf("原来的缓存应该保留: %q %v %v", body, err, ok)
+	}
+	it, err := GetJSON[item](ctx, c, ts.URL, ReqWithCachePolicy(CachePolicy{MaxAge: time.Nanosecond, StaleIfError: true}))
+	if err != nil || it.Price != 12 {
+		t.Fatalf("StaleIfError 时应该返回缓存: %+v %v", it, err)
+	}
+}
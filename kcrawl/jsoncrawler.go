@@ -1,12 +1,9 @@
 package kcrawl
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/kevin-zx/kbase/kcache"
 )
@@ -17,13 +14,19 @@ type Crawler interface {
 	Post(url string, body string, data interface{}) error
 	GetTryCache(url []string, data interface{}) (bool, error)
 	PostTryCache(urls []string, payloads []string, data interface{}) (bool, error)
+
+	GetCtx(ctx context.Context, url string, data interface{}) error
+	PostCtx(ctx context.Context, url string, body string, data interface{}) error
 }
 
 type cacheCrawler struct {
-	cacheDir          kcache.KCache
-	header            http.Header
+	cacheDir kcache.KCache
+	// 实际发起请求的 crawler，每次请求后等待 5 秒
+	raw               rawCrawler
 	preHandles        []func([]byte) []byte
 	reCombineCacheKey func(string) string
+	// 为空时使用 url + payload 作为缓存 key
+	cacheKeyer CacheKeyer
 }
 
 func NewCacheCrawler(cacheDir string, header map[string]string, cos ...CrawlerOption) Crawler {
@@ -33,7 +36,10 @@ func NewCacheCrawler(cacheDir string, header map[string]string, cos ...CrawlerOp
 	}
 	c := &cacheCrawler{
 		cacheDir: kcache.NewFileCache(cacheDir),
-		header:   httpHeader,
+		raw: rawCrawler{
+			header:          httpHeader,
+			intervalSeconds: 5,
+		},
 	}
 	for _, co := range cos {
 		co(c)
@@ -42,11 +48,15 @@ func NewCacheCrawler(cacheDir string, header map[string]string, cos ...CrawlerOp
 }
 
 func (c *cacheCrawler) DeleteCache(url string) error {
-	return c.cacheDir.Delete(url)
+	return c.cacheDir.Delete(c.generateCacheKey("GET", url, ""))
 }
 
 func (c *cacheCrawler) Post(url string, payload string, data interface{}) error {
-	raw, err := c.cacheDir.Get(url + payload)
+	return c.PostCtx(context.Background(), url, payload, data)
+}
+
+func (c *cacheCrawler) PostCtx(ctx context.Context, url string, payload string, data interface{}) error {
+	raw, err := c.cacheDir.Get(c.generateCacheKey("POST", url, payload))
 	if err != nil {
 		return err
 	}
@@ -57,40 +67,35 @@ func (c *cacheCrawler) Post(url string, payload string, data interface{}) error
 		}
 		return nil
 	}
-	method := "POST"
-	payloadr := strings.NewReader(payload)
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payloadr)
-	if err != nil {
-		return err
-	}
-	req.Header = c.header
-	res, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-	time.Sleep(5 * time.Second)
-	body, err := io.ReadAll(res.Body)
+	body, err := c.fetch(ctx, &Request{Method: "POST", URL: url, Payload: payload})
 	if err != nil {
 		return err
 	}
-	if res.StatusCode != 200 {
-		return fmt.Errorf("status code error: %d %s and body is: %s", res.StatusCode, res.Status, string(body))
-	}
 	for _, preHandle := range c.preHandles {
 		body = preHandle(body)
 	}
 	err = json.Unmarshal(body, data)
 	if err != nil {
-		return fmt.Errorf("unmarshal error: %s and body is: %s", err.Error(), string(body))
+		return newDecodeError(url, body, err)
+	}
+	return c.cacheDir.Save(c.generateCacheKey("POST", url, payload), body)
+}
+
+// fetch 和原来一样只接受 200，其它 2xx 也当作错误，不会写入缓存
+func (c *cacheCrawler) fetch(ctx context.Context, r *Request) ([]byte, error) {
+	res, err := c.raw.DoResponse(ctx, r)
+	if err != nil {
+		return nil, err
 	}
-	return c.cacheDir.Save(c.generateCacheKey(url, payload), body)
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: res.Body}
+	}
+	return res.Body, nil
 }
 
 func (c *cacheCrawler) PostTryCache(urls []string, payloads []string, data interface{}) (bool, error) {
 	for i, url := range urls {
-		raw, err := c.cacheDir.Get(c.generateCacheKey(url, payloads[i]))
+		raw, err := c.cacheDir.Get(c.generateCacheKey("POST", url, payloads[i]))
 		if err != nil {
 			return false, err
 		}
@@ -105,9 +110,12 @@ func (c *cacheCrawler) PostTryCache(urls []string, payloads []string, data inter
 	return false, nil
 }
 
-// 如果payload为空，那么就是get请求
-func (c *cacheCrawler) generateCacheKey(url string, payload string) string {
+// 没有设置 cacheKeyer 时不区分 method，如果payload为空，那么就是get请求
+func (c *cacheCrawler) generateCacheKey(method string, url string, payload string) string {
 	cacheKey := url + payload
+	if c.cacheKeyer != nil {
+		cacheKey = c.cacheKeyer.CacheKey(&Request{Method: method, URL: url, Payload: payload, Header: mergeHeader(c.raw.header, nil)})
+	}
 	if c.reCombineCacheKey != nil {
 		cacheKey = c.reCombineCacheKey(cacheKey)
 	}
@@ -116,7 +124,7 @@ func (c *cacheCrawler) generateCacheKey(url string, payload string) string {
 
 func (c *cacheCrawler) GetTryCache(url []string, data interface{}) (bool, error) {
 	for _, u := range url {
-		raw, err := c.cacheDir.Get(c.generateCacheKey(u, ""))
+		raw, err := c.cacheDir.Get(c.generateCacheKey("GET", u, ""))
 		if err != nil {
 			return false, err
 		}
@@ -132,7 +140,11 @@ func (c *cacheCrawler) GetTryCache(url []string, data interface{}) (bool, error)
 }
 
 func (c *cacheCrawler) Get(url string, data interface{}) error {
-	raw, err := c.cacheDir.Get(url)
+	return c.GetCtx(context.Background(), url, data)
+}
+
+func (c *cacheCrawler) GetCtx(ctx context.Context, url string, data interface{}) error {
+	raw, err := c.cacheDir.Get(c.generateCacheKey("GET", url, ""))
 	if err != nil {
 		return err
 	}
@@ -144,36 +156,17 @@ func (c *cacheCrawler) Get(url string, data interface{}) error {
 		return nil
 	}
 
-	method := "GET"
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return err
-	}
-	req.Header = c.header
-	res, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-	time.Sleep(5 * time.Second)
-	body, err := io.ReadAll(res.Body)
+	body, err := c.fetch(ctx, &Request{Method: "GET", URL: url})
 	if err != nil {
 		return err
 	}
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("status code error: %d %s and body is: %s", res.StatusCode, res.Status, string(body))
-	}
-
 	for _, preHandle := range c.preHandles {
 		body = preHandle(body)
 	}
 	err = json.Unmarshal(body, data)
 	if err != nil {
-		fmt.Println(string(body))
-		return err
+		return newDecodeError(url, body, err)
 	}
-	return c.cacheDir.Save(c.generateCacheKey(url, ""), body)
+	return c.cacheDir.Save(c.generateCacheKey("GET", url, ""), body)
 }
@@ -14,3 +14,52 @@ func WithRecombineCacheKey(handleKey func(string) string) CrawlerOption {
 		c.reCombineCacheKey = handleKey
 	}
 }
+
+// WithRetry 请求失败时按 policy 重试
+func WithRetry(policy RetryPolicy) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.raw.retry = &policy
+	}
+}
+
+// WithRateLimiter 使用 limiter 控制请求频率，代替默认的每次请求后等待 5 秒
+func WithRateLimiter(limiter RateLimiter) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.raw.limiter = limiter
+	}
+}
+
+// WithSession 使用 session 保存和发送 cookie
+func WithSession(session *Session) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.raw.jar = session
+	}
+}
+
+// WithCacheKeyer 使用 keyer 生成缓存 key，WithRecombineCacheKey 会在 keyer 之后处理
+func WithCacheKeyer(keyer CacheKeyer) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.cacheKeyer = keyer
+	}
+}
+
+// WithRobots 遵守 robots.txt，被禁止的请求返回 ErrDisallowedByRobots
+func WithRobots(policy *RobotsPolicy) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.raw.robots = policy
+	}
+}
+
+// WithRecorder 通过 rec 记录或者回放请求，用于离线测试
+func WithRecorder(rec *Recorder) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.raw.recorder = rec
+	}
+}
+
+// WithValidators 校验失败的响应不会缓存；校验的是 preHandles 处理之前的 body
+func WithValidators(validators ...Validator) CrawlerOption {
+	return func(c *cacheCrawler) {
+		c.raw.validators = append(c.raw.validators, validators...)
+	}
+}
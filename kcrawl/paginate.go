@@ -0,0 +1,97 @@
+package kcrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// ErrPageLoop 下一页的位置和当前页相同，继续翻页会死循环
+var ErrPageLoop = errors.New("kcrawl: next page is the same as current page")
+
+// PageState 分页的位置，按接口的分页方式使用其中一个字段
+type PageState struct {
+	// 页码，第一页由 Paginator.Start 决定
+	Page int
+	// 已经读取的条数
+	Offset int
+	// 上一页返回的游标，第一页为空
+	Cursor string
+}
+
+// NextPage 页码和 offset 翻页时的下一页，n 为这一页的条数
+func (s PageState) NextPage(n int) PageState {
+	return PageState{Page: s.Page + 1, Offset: s.Offset + n}
+}
+
+// NextCursor 游标翻页时的下一页
+func (s PageState) NextCursor(cursor string) PageState {
+	return PageState{Page: s.Page + 1, Offset: s.Offset, Cursor: cursor}
+}
+
+// Paginator 分页抓取，每一页单独缓存，中断后重新运行时已经缓存的页直接从缓存读取，从第一个没有缓存的页继续抓取
+type Paginator[T any] struct {
+	// Start 第一页的位置，一般是 PageState{Page: 1} 或者 PageState{}
+	Start PageState
+	// Request 根据位置构造请求
+	Request func(s PageState) *Request
+	// Parse 解析一页，返回这一页的数据和下一页的位置，没有下一页时 more 为 false
+	Parse func(res *Response, s PageState) (items []T, next PageState, more bool, err error)
+	// MaxPages 最多抓取的页数，0 表示不限制
+	MaxPages int
+	// SkipCached 为 true 时不返回缓存中的页的数据，用于中断后继续处理新的数据
+	SkipCached bool
+}
+
+// Paginate 依次返回每一页的数据，出错时返回错误并结束。解析失败的页会删除缓存
+func Paginate[T any](ctx context.Context, crawler RawCacheCrawler, p Paginator[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		s := p.Start
+		for pages := 0; p.MaxPages <= 0 || pages < p.MaxPages; pages++ {
+			r := p.Request(s)
+			res, err := crawler.DoResponse(ctx, r)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			items, next, more, err := p.Parse(res, s)
+			if err != nil {
+				if derr := crawler.DeleteRequestCache(r); derr != nil {
+					err = errors.Join(err, derr)
+				}
+				yield(zero, fmt.Errorf("parse page %+v: %w", s, err))
+				return
+			}
+			if !res.FromCache || !p.SkipCached {
+				for _, item := range items {
+					if !yield(item, nil) {
+						return
+					}
+				}
+			}
+			if !more {
+				return
+			}
+			if next == s {
+				yield(zero, fmt.Errorf("%w: %+v", ErrPageLoop, s))
+				return
+			}
+			s = next
+		}
+	}
+}
+
+// JSONPage 把 body 解析成 P 之后交给 parse，用来构造 Paginator.Parse
+func JSONPage[P, T any](parse func(page P, s PageState) (items []T, next PageState, more bool)) func(res *Response, s PageState) ([]T, PageState, bool, error) {
+	return func(res *Response, s PageState) ([]T, PageState, bool, error) {
+		var page P
+		if err := json.Unmarshal(res.Body, &page); err != nil {
+			return nil, s, false, newDecodeError(res.URL, res.Body, err)
+		}
+		items, next, more := parse(page, s)
+		return items, next, more, nil
+	}
+}
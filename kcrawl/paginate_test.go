@@ -0,0 +1,76 @@
+package kcrawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	var hits atomic.Int32
+	var broken atomic.Bool
+	broken.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		if page == 2 && broken.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		next := ""
+		if page < 2 {
+			next = strconv.Itoa(page + 1)
+		}
+		fmt.Fprintf(w, `{"items":[%d,%d],"next":%q}`, page*2, page*2+1, next)
+	}))
+	defer ts.Close()
+
+	type resp struct {
+		Items []int  `json:"items"`
+		Next  string `json:"next"`
+	}
+	p := Paginator[int]{
+		Request: func(s PageState) *Request {
+			return &Request{URL: ts.URL + "/list?cursor=" + s.Cursor}
+		},
+		Parse: JSONPage(func(page resp, s PageState) ([]int, PageState, bool) {
+			return page.Items, s.NextCursor(page.Next), page.Next != ""
+		}),
+	}
+	c := NewRawCacheCrawler(newTestCache(t))
+	collect := func(p Paginator[int]) ([]int, error) {
+		var items []int
+		for item, err := range Paginate(context.Background(), c, p) {
+			if err != nil {
+				return items, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	items, err := collect(p)
+	if err == nil || fmt.Sprint(items) != "[0 1 2 3]" {
+		t.Fatalf("第三页应该失败: %v %v", items, err)
+	}
+
+	// 中断后继续，已经缓存的两页不再请求
+	broken.Store(false)
+	hits.Store(0)
+	p.SkipCached = true
+	items, err = collect(p)
+	if err != nil || fmt.Sprint(items) != "[4 5]" || hits.Load() != 1 {
+		t.Fatalf("应该从第三页继续: %v %v, 请求 %d 次", items, err, hits.Load())
+	}
+
+	p.SkipCached = false
+	p.MaxPages = 2
+	items, err = collect(p)
+	if err != nil || fmt.Sprint(items) != "[0 1 2 3]" || hits.Load() != 1 {
+		t.Fatalf("MaxPages 不对: %v %v, 请求 %d 次", items, err, hits.Load())
+	}
+}
@@ -0,0 +1,271 @@
+package kcrawl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// ErrSkipped fail-fast 模式下，前面的请求失败后没有执行的请求会返回这个错误
+var ErrSkipped = errors.New("kcrawl: request skipped after an earlier failure")
+
+// Doer 可以执行 Request 的 crawler，RawCrawler 和 RawCacheCrawler 都实现了它
+type Doer interface {
+	Do(ctx context.Context, r *Request) ([]byte, error)
+}
+
+// BatchResult 单个请求的结果，Index 是请求在输入中的序号
+type BatchResult struct {
+	Index   int
+	Request *Request
+	Body    []byte
+	Err     error
+}
+
+// Pool 并发执行请求的 worker pool
+type Pool struct {
+	doer            Doer
+	workers         int
+	hostConcurrency int
+	failFast        bool
+	ordered         bool
+	progress        func(done int, total int, r BatchResult)
+
+	mu       sync.Mutex
+	hostSems map[string]chan struct{}
+}
+
+type PoolOption func(p *Pool)
+
+// PoolWithWorkers worker 数量，默认 4
+func PoolWithWorkers(n int) PoolOption {
+	return func(p *Pool) {
+		p.workers = n
+	}
+}
+
+// PoolWithHostConcurrency 同一个 host 同时进行的请求数上限，0 表示不限制
+func PoolWithHostConcurrency(n int) PoolOption {
+	return func(p *Pool) {
+		p.hostConcurrency = n
+	}
+}
+
+// PoolWithFailFast 有请求失败时停止派发剩下的请求，默认收集所有结果
+func PoolWithFailFast(failFast bool) PoolOption {
+	return func(p *Pool) {
+		p.failFast = failFast
+	}
+}
+
+// PoolWithOrdered 按输入顺序输出结果，默认按完成顺序输出
+func PoolWithOrdered(ordered bool) PoolOption {
+	return func(p *Pool) {
+		p.ordered = ordered
+	}
+}
+
+// PoolWithProgress 每完成一个请求回调一次，total 未知时（Stream）为 0；回调是串行的
+func PoolWithProgress(progress func(done int, total int, r BatchResult)) PoolOption {
+	return func(p *Pool) {
+		p.progress = progress
+	}
+}
+
+func NewPool(doer Doer, opts ...PoolOption) *Pool {
+	p := &Pool{
+		doer:     doer,
+		workers:  4,
+		hostSems: make(map[string]chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.workers < 1 {
+		p.workers = 1
+	}
+	return p
+}
+
+// Batch 用一个临时的 Pool 执行 reqs，结果按输入顺序返回
+func Batch(ctx context.Context, doer Doer, reqs []*Request, opts ...PoolOption) ([]BatchResult, error) {
+	return NewPool(doer, opts...).Collect(ctx, reqs)
+}
+
+// Run 执行 reqs，返回的 channel 需要读完，或者取消 ctx
+func (p *Pool) Run(ctx context.Context, reqs []*Request) <-chan BatchResult {
+	// stream 结束时（包括 fail-fast 提前结束）取消 feed，避免 goroutine 阻塞在 in <- r
+	ctx, stop := context.WithCancel(ctx)
+	in := make(chan *Request)
+	go func() {
+		defer close(in)
+		for _, r := range reqs {
+			select {
+			case in <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p.stream(ctx, in, len(reqs), stop)
+}
+
+// Stream 从 in 读取请求直到 in 关闭，返回的 channel 需要读完，或者取消 ctx
+func (p *Pool) Stream(ctx context.Context, in <-chan *Request) <-chan BatchResult {
+	return p.stream(ctx, in, 0, nil)
+}
+
+// Collect 执行 reqs 并等待全部完成，结果按输入顺序返回。
+// fail-fast 模式下返回第一个错误，没有执行的请求 Err 为 ErrSkipped；否则 error 只在 ctx 取消时返回
+func (p *Pool) Collect(ctx context.Context, reqs []*Request) ([]BatchResult, error) {
+	results := make([]BatchResult, len(reqs))
+	for i, r := range reqs {
+		results[i] = BatchResult{Index: i, Request: r, Err: ErrSkipped}
+	}
+	var firstErr error
+	for r := range p.Run(ctx, reqs) {
+		results[r.Index] = r
+		if r.Err != nil && firstErr == nil {
+			firstErr = r.Err
+		}
+	}
+	if ctx.Err() != nil {
+		return results, ctx.Err()
+	}
+	if p.failFast {
+		return results, firstErr
+	}
+	return results, nil
+}
+
+// stream 结束后调用 stop（可以为空），用来结束 in 的生产者
+func (p *Pool) stream(parent context.Context, in <-chan *Request, total int, stop context.CancelFunc) <-chan BatchResult {
+	ctx, cancel := context.WithCancel(parent)
+	jobs := make(chan BatchResult)
+	results := make(chan BatchResult)
+	out := make(chan BatchResult)
+
+	go func() {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			var r *Request
+			var ok bool
+			select {
+			case r, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- BatchResult{Index: i, Request: r}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < p.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.Body, j.Err = p.do(ctx, j.Request)
+				if j.Err != nil && errors.Is(j.Err, context.Canceled) && ctx.Err() != nil && parent.Err() == nil {
+					// fail-fast 取消了正在进行的请求
+					j.Body, j.Err = nil, ErrSkipped
+				}
+				results <- j
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		if stop != nil {
+			defer stop()
+		}
+		send := func(r BatchResult) {
+			select {
+			case out <- r:
+			case <-parent.Done():
+			}
+		}
+		done := 0
+		next := 0
+		pending := make(map[int]BatchResult)
+		for r := range results {
+			done++
+			if r.Err != nil && p.failFast {
+				cancel()
+			}
+			if p.progress != nil {
+				p.progress(done, total, r)
+			}
+			if !p.ordered {
+				send(r)
+				continue
+			}
+			pending[r.Index] = r
+			for {
+				x, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				send(x)
+				next++
+			}
+		}
+		// fail-fast 时中间可能有请求没有执行，剩下的按顺序输出
+		rest := make([]int, 0, len(pending))
+		for i := range pending {
+			rest = append(rest, i)
+		}
+		sort.Ints(rest)
+		for _, i := range rest {
+			send(pending[i])
+		}
+	}()
+	return out
+}
+
+func (p *Pool) do(ctx context.Context, r *Request) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p.hostConcurrency > 0 {
+		sem := p.hostSem(r.URL)
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+	}
+	return p.doer.Do(ctx, r)
+}
+
+func (p *Pool) hostSem(rawURL string) chan struct{} {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sem, ok := p.hostSems[host]
+	if !ok {
+		sem = make(chan struct{}, p.hostConcurrency)
+		p.hostSems[host] = sem
+	}
+	return sem
+}
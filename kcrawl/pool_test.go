@@ -0,0 +1,114 @@
+package kcrawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeDoer struct {
+	running atomic.Int32
+	maxRun  atomic.Int32
+}
+
+func (d *fakeDoer) Do(ctx context.Context, r *Request) ([]byte, error) {
+	n := d.running.Add(1)
+	defer d.running.Add(-1)
+	for {
+		m := d.maxRun.Load()
+		if n <= m || d.maxRun.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if r.Payload == "fail" {
+		return nil, errors.New("fail")
+	}
+	return []byte(r.URL), nil
+}
+
+func TestPoolCollect(t *testing.T) {
+	var reqs []*Request
+	for i := 0; i < 20; i++ {
+		reqs = append(reqs, &Request{URL: fmt.Sprintf("http://h%d.com/%d", i%2, i)})
+	}
+	d := &fakeDoer{}
+	var progress atomic.Int32
+	results, err := Batch(context.Background(), d, reqs,
+		PoolWithWorkers(8),
+		PoolWithHostConcurrency(1),
+		PoolWithProgress(func(done, total int, r BatchResult) { progress.Add(1) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Index != i || string(r.Body) != reqs[i].URL {
+			t.Fatalf("结果 %d 顺序不对: %+v", i, r)
+		}
+	}
+	if d.maxRun.Load() > 2 {
+		t.Fatalf("每个 host 最多 1 个并发, 两个 host 最多 2 个, 实际 %d", d.maxRun.Load())
+	}
+	if progress.Load() != 20 {
+		t.Fatalf("进度回调应该调用 20 次, 实际 %d", progress.Load())
+	}
+}
+
+func TestPoolFailFast(t *testing.T) {
+	var reqs []*Request
+	for i := 0; i < 50; i++ {
+		reqs = append(reqs, &Request{URL: fmt.Sprint(i)})
+	}
+	reqs[0].Payload = "fail"
+	results, err := Batch(context.Background(), &fakeDoer{}, reqs, PoolWithWorkers(1), PoolWithFailFast(true))
+	if err == nil {
+		t.Fatal("fail-fast 应该返回错误")
+	}
+	if !errors.Is(results[len(results)-1].Err, ErrSkipped) {
+		t.Fatalf("最后一个请求应该被跳过, 得到 %v", results[len(results)-1].Err)
+	}
+}
+
+// blockingDoer 失败的请求立即返回，其它请求一直等到 ctx 取消
+type blockingDoer struct{}
+
+func (blockingDoer) Do(ctx context.Context, r *Request) ([]byte, error) {
+	if r.Payload == "fail" {
+		time.Sleep(5 * time.Millisecond)
+		return nil, errors.New("fail")
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestPoolFailFastNoLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		var reqs []*Request
+		for k := 0; k < 10; k++ {
+			reqs = append(reqs, &Request{URL: fmt.Sprint(k)})
+		}
+		reqs[1].Payload = "fail"
+		results, err := Batch(context.Background(), blockingDoer{}, reqs, PoolWithWorkers(2), PoolWithFailFast(true))
+		if err == nil || err.Error() != "fail" {
+			t.Fatalf("应该返回第一个错误, 得到 %v", err)
+		}
+		for _, r := range results {
+			if r.Request.Payload != "fail" && !errors.Is(r.Err, ErrSkipped) {
+				t.Fatalf("被取消的请求应该返回 ErrSkipped, 得到 %v", r.Err)
+			}
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("goroutine 泄漏: 之前 %d, 之后 %d", before, n)
+	}
+}
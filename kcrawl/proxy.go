@@ -1,8 +1,268 @@
 package kcrawl
 
-import "net/url"
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
 
 type ProxyPool interface {
 	GetHttpProxy() *url.URL
 	GetSock5Proxy() *url.URL
 }
+
+// ProxyFeedback ProxyPool 可以选择实现这个接口，crawler 会把每次使用代理的结果报告回来
+type ProxyFeedback interface {
+	ReportSuccess(proxy *url.URL)
+	ReportFailure(proxy *url.URL, err error)
+}
+
+// ProxyStrategy 代理的选择策略
+type ProxyStrategy int
+
+const (
+	// ProxyRoundRobin 依次轮换
+	ProxyRoundRobin ProxyStrategy = iota
+	// ProxyWeighted 按权重随机，连续失败的代理权重会降低
+	ProxyWeighted
+)
+
+// ProxyStat 代理的使用情况
+type ProxyStat struct {
+	URL       *url.URL
+	Weight    int
+	Successes int
+	Failures  int
+	// 连续失败次数，成功一次清零
+	ConsecutiveFailures int
+	// 进入冷却的次数
+	Cooldowns     int
+	CooldownUntil time.Time
+	Evicted       bool
+}
+
+// RotatingProxyPool 轮换代理池，实现了 ProxyPool 和 ProxyFeedback，可以在多个 crawler 之间共用
+type RotatingProxyPool struct {
+	mu       sync.Mutex
+	proxies  []*ProxyStat
+	next     int
+	strategy ProxyStrategy
+	// 连续失败 maxFailures 次进入冷却
+	maxFailures int
+	cooldown    time.Duration
+	// 冷却 evictAfter 次后移除，0 表示不移除
+	evictAfter int
+	rand       *rand.Rand
+	now        func() time.Time
+	// 每个代理的 Transport，在使用这个池的 crawler 之间共用，代理被移除时关闭
+	transports proxyTransports
+}
+
+type RotatingProxyOption func(p *RotatingProxyPool)
+
+// WithProxyStrategy 默认 ProxyRoundRobin
+func WithProxyStrategy(strategy ProxyStrategy) RotatingProxyOption {
+	return func(p *RotatingProxyPool) {
+		p.strategy = strategy
+	}
+}
+
+// WithProxyCooldown 连续失败 maxFailures 次后冷却 cooldown 时间，默认连续失败 3 次冷却 1 分钟
+func WithProxyCooldown(maxFailures int, cooldown time.Duration) RotatingProxyOption {
+	return func(p *RotatingProxyPool) {
+		p.maxFailures = maxFailures
+		p.cooldown = cooldown
+	}
+}
+
+// WithProxyEvictAfter 冷却 n 次后从池中移除，默认不移除
+func WithProxyEvictAfter(n int) RotatingProxyOption {
+	return func(p *RotatingProxyPool) {
+		p.evictAfter = n
+	}
+}
+
+// NewRotatingProxyPool 每个代理的格式为 "scheme://[user:pass@]host:port [weight]"，没有 scheme 时当作 http 代理。
+// 支持 http、https、socks5、socks5h
+func NewRotatingProxyPool(proxies []string, opts ...RotatingProxyOption) (*RotatingProxyPool, error) {
+	p := &RotatingProxyPool{
+		maxFailures: 3,
+		cooldown:    time.Minute,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for _, line := range proxies {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ps, err := parseProxyLine(line)
+		if err != nil {
+			return nil, err
+		}
+		p.proxies = append(p.proxies, ps)
+	}
+	if len(p.proxies) == 0 {
+		return nil, errors.New("kcrawl: no proxy in pool")
+	}
+	return p, nil
+}
+
+// LoadRotatingProxyPool 从文件加载代理，每行一个，# 开头的行是注释
+func LoadRotatingProxyPool(path string, opts ...RotatingProxyOption) (*RotatingProxyPool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewRotatingProxyPool(lines, opts...)
+}
+
+func parseProxyLine(line string) (*ProxyStat, error) {
+	fields := strings.Fields(line)
+	raw := fields[0]
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("kcrawl: invalid proxy %q: %w", line, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("kcrawl: unsupported proxy scheme %q", u.Scheme)
+	}
+	ps := &ProxyStat{URL: u, Weight: 1}
+	if len(fields) > 1 {
+		ps.Weight, err = strconv.Atoi(fields[1])
+		if err != nil || ps.Weight < 1 {
+			return nil, fmt.Errorf("kcrawl: invalid proxy weight %q", line)
+		}
+	}
+	return ps, nil
+}
+
+func isSocks5(u *url.URL) bool {
+	return u.Scheme == "socks5" || u.Scheme == "socks5h"
+}
+
+// GetHttpProxy 返回一个可用的 http/https 代理，没有时返回 nil
+func (p *RotatingProxyPool) GetHttpProxy() *url.URL {
+	return p.pick(false)
+}
+
+// GetSock5Proxy 返回一个可用的 socks5 代理，没有时返回 nil
+func (p *RotatingProxyPool) GetSock5Proxy() *url.URL {
+	return p.pick(true)
+}
+
+func (p *RotatingProxyPool) pick(socks5 bool) *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	var candidates []*ProxyStat
+	for _, ps := range p.proxies {
+		if ps.Evicted || isSocks5(ps.URL) != socks5 || now.Before(ps.CooldownUntil) {
+			continue
+		}
+		candidates = append(candidates, ps)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if p.strategy == ProxyWeighted {
+		total := 0.0
+		weights := make([]float64, len(candidates))
+		for i, ps := range candidates {
+			weights[i] = float64(ps.Weight) / float64(ps.ConsecutiveFailures+1)
+			total += weights[i]
+		}
+		r := p.rand.Float64() * total
+		for i, w := range weights {
+			if r < w {
+				return candidates[i].URL
+			}
+			r -= w
+		}
+		return candidates[len(candidates)-1].URL
+	}
+	ps := candidates[p.next%len(candidates)]
+	p.next++
+	return ps.URL
+}
+
+func (p *RotatingProxyPool) find(proxy *url.URL) *ProxyStat {
+	if proxy == nil {
+		return nil
+	}
+	for _, ps := range p.proxies {
+		if ps.URL.String() == proxy.String() {
+			return ps
+		}
+	}
+	return nil
+}
+
+func (p *RotatingProxyPool) ReportSuccess(proxy *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ps := p.find(proxy); ps != nil {
+		ps.Successes++
+		ps.ConsecutiveFailures = 0
+	}
+}
+
+func (p *RotatingProxyPool) ReportFailure(proxy *url.URL, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.find(proxy)
+	if ps == nil {
+		return
+	}
+	ps.Failures++
+	ps.ConsecutiveFailures++
+	if p.maxFailures > 0 && ps.ConsecutiveFailures >= p.maxFailures {
+		ps.ConsecutiveFailures = 0
+		ps.Cooldowns++
+		ps.CooldownUntil = p.now().Add(p.cooldown)
+		if p.evictAfter > 0 && ps.Cooldowns >= p.evictAfter {
+			ps.Evicted = true
+			p.transports.remove(ps.URL)
+		}
+	}
+}
+
+func (p *RotatingProxyPool) proxyTransport(proxy *url.URL) *http.Transport {
+	return p.transports.get(proxy)
+}
+
+// Stats 返回所有代理的使用情况
+func (p *RotatingProxyPool) Stats() []ProxyStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]ProxyStat, len(p.proxies))
+	for i, ps := range p.proxies {
+		stats[i] = *ps
+	}
+	return stats
+}
@@ -0,0 +1,267 @@
+package kcrawl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestHTTPProxy 简单的 http 转发代理，只支持明文 http
+func newTestHTTPProxy(t *testing.T, hits *atomic.Int32) *httptest.Server {
+	ts := httptest.NewServer(testProxyHandler(hits))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func testProxyHandler(hits *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		res, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, res.Body)
+	})
+}
+
+// newTestSocks5Proxy 只支持无认证 CONNECT 的 socks5 代理
+func newTestSocks5Proxy(t *testing.T, hits *atomic.Int32) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				hits.Add(1)
+				buf := make([]byte, 262)
+				// 协商认证方式
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+				// CONNECT 请求
+				if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+					return
+				}
+				var host string
+				switch buf[3] {
+				case 1:
+					io.ReadFull(conn, buf[:4])
+					host = net.IP(buf[:4]).String()
+				case 3:
+					io.ReadFull(conn, buf[:1])
+					n := int(buf[0])
+					io.ReadFull(conn, buf[:n])
+					host = string(buf[:n])
+				default:
+					return
+				}
+				io.ReadFull(conn, buf[:2])
+				port := binary.BigEndian.Uint16(buf[:2])
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+	return "socks5://" + ln.Addr().String()
+}
+
+func deadProxyAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestRotatingProxyPool(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	var hits atomic.Int32
+	live := newTestHTTPProxy(t, &hits)
+	dead := deadProxyAddr(t)
+	pool, err := NewRotatingProxyPool([]string{dead, live.URL}, WithProxyCooldown(1, time.Hour), WithProxyEvictAfter(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	c := NewRawCrawler(0, nil, RawWithProxyPool(pool), RawWithRetry(policy))
+	for i := 0; i < 3; i++ {
+		data, err := c.Get(target.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "ok" {
+			t.Fatalf("期望 ok, 得到 %q", data)
+		}
+	}
+	if hits.Load() != 3 {
+		t.Fatalf("期望经过可用代理 3 次, 实际 %d", hits.Load())
+	}
+	stats := pool.Stats()
+	if !stats[0].Evicted || stats[0].Failures != 1 {
+		t.Fatalf("不可用的代理应该被移除: %+v", stats[0])
+	}
+	if stats[1].Successes != 3 {
+		t.Fatalf("可用代理应该成功 3 次: %+v", stats[1])
+	}
+}
+
+func TestRotatingProxyPoolSocks5(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	var hits atomic.Int32
+	pool, err := NewRotatingProxyPool([]string{newTestSocks5Proxy(t, &hits)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.GetHttpProxy() != nil {
+		t.Fatal("没有 http 代理时应该返回 nil")
+	}
+	data, err := NewRawCrawler(0, nil, RawWithProxyPool(pool)).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "ok" || hits.Load() != 1 {
+		t.Fatalf("应该通过 socks5 代理请求, hits=%d data=%q", hits.Load(), data)
+	}
+}
+
+func TestRotatingProxyPoolWeighted(t *testing.T) {
+	pool, err := NewRotatingProxyPool([]string{"http://a:1 9", "http://b:1 1"}, WithProxyStrategy(ProxyWeighted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		count[pool.GetHttpProxy().Host]++
+	}
+	if count["a:1"] < 800 {
+		t.Fatalf("权重 9:1 时 a 应该占大多数: %v", count)
+	}
+}
+
+func TestProxyTransportReuse(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	var hits, conns atomic.Int32
+	live := httptest.NewUnstartedServer(testProxyHandler(&hits))
+	live.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	live.Start()
+	defer live.Close()
+	pool, err := NewRotatingProxyPool([]string{live.URL}, WithProxyCooldown(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewRawCrawler(0, nil, RawWithProxyPool(pool))
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(target.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conns.Load() != 1 {
+		t.Fatalf("同一个代理应该复用连接, 建立了 %d 个连接", conns.Load())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Do(ctx, &Request{URL: target.URL + "/slow"}); err == nil {
+		t.Fatal("超时应该返回错误")
+	}
+	if stats := pool.Stats(); stats[0].Failures != 0 {
+		t.Fatalf("ctx 超时不应该算作代理失败: %+v", stats[0])
+	}
+}
+
+func TestProxyTransportEvict(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	var hits atomic.Int32
+	closed := make(chan struct{}, 1)
+	live := httptest.NewUnstartedServer(testProxyHandler(&hits))
+	live.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	live.Start()
+	defer live.Close()
+	pool, err := NewRotatingProxyPool([]string{live.URL}, WithProxyCooldown(1, time.Hour), WithProxyEvictAfter(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewRawCrawler(0, nil, RawWithProxyPool(pool))
+	if _, err := c.Get(target.URL); err != nil {
+		t.Fatal(err)
+	}
+	proxy := pool.Stats()[0].URL
+	if _, ok := pool.transports.m.Load(proxy.String()); !ok {
+		t.Fatal("代理池应该保存代理的 Transport")
+	}
+
+	pool.ReportFailure(proxy, errors.New("bad proxy"))
+	if _, ok := pool.transports.m.Load(proxy.String()); ok || !pool.Stats()[0].Evicted {
+		t.Fatal("移除代理时应该删除它的 Transport")
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("移除代理时应该关闭它的空闲连接")
+	}
+}
@@ -0,0 +1,145 @@
+package kcrawl
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter 控制请求频率，实现需要保证并发安全，这样多个 crawler 可以共用一个限速器
+type RateLimiter interface {
+	// Wait 阻塞直到可以向 host 发起请求
+	Wait(ctx context.Context, host string) error
+}
+
+// tokenBucket 令牌桶，rate 为每秒产生的令牌数，burst 为桶的容量
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// reserve 预定一个令牌，返回需要等待的时间
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel 归还预定的令牌
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return
+	}
+	b.tokens++
+}
+
+func (b *tokenBucket) set(rate float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if burst < 1 {
+		burst = 1
+	}
+	b.rate = rate
+	b.burst = float64(burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	d := b.reserve()
+	if err := sleepCtx(ctx, d); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+type tokenBucketLimiter struct {
+	bucket *tokenBucket
+}
+
+// NewTokenBucketLimiter 所有 host 共用一个令牌桶，rate 为每秒请求数，rate <= 0 表示不限速
+func NewTokenBucketLimiter(rate float64, burst int) RateLimiter {
+	return &tokenBucketLimiter{bucket: newTokenBucket(rate, burst)}
+}
+
+func (l *tokenBucketLimiter) Wait(ctx context.Context, host string) error {
+	return l.bucket.wait(ctx)
+}
+
+// HostRateLimiter 每个 host 一个令牌桶，不同 host 之间互不影响
+type HostRateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	buckets map[string]*tokenBucket
+}
+
+// NewHostRateLimiter 每个 host 默认每秒 rate 个请求，rate <= 0 表示不限速
+func NewHostRateLimiter(rate float64, burst int) *HostRateLimiter {
+	return &HostRateLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Every 把请求间隔换算成 rate，比如 Every(5*time.Second) 等于每 5 秒一个请求
+func Every(interval time.Duration) float64 {
+	if interval <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(interval)
+}
+
+func (l *HostRateLimiter) bucket(host string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[host]
+	if !ok {
+		b = newTokenBucket(l.rate, l.burst)
+		l.buckets[host] = b
+	}
+	return b
+}
+
+// SetHostRate 单独设置某个 host 的频率
+func (l *HostRateLimiter) SetHostRate(host string, rate float64, burst int) {
+	l.bucket(host).set(rate, burst)
+}
+
+func (l *HostRateLimiter) Wait(ctx context.Context, host string) error {
+	return l.bucket(host).wait(ctx)
+}
@@ -0,0 +1,76 @@
+package kcrawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kevin-zx/kbase/kcache"
+)
+
+func TestHostRateLimiter(t *testing.T) {
+	l := NewHostRateLimiter(Every(50*time.Millisecond), 1)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "a.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("同一个 host 3 次请求应该至少等待 100ms, 实际 %v", d)
+	}
+
+	// 其它 host 不受影响
+	start = time.Now()
+	if err := l.Wait(ctx, "b.com"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Fatalf("不同 host 不应该等待, 实际 %v", d)
+	}
+
+	// 取消时立即返回
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.Wait(cctx, "a.com"); err == nil {
+		t.Fatal("context 取消后应该返回错误")
+	}
+}
+
+type countLimiter struct {
+	n atomic.Int32
+}
+
+func (l *countLimiter) Wait(ctx context.Context, host string) error {
+	l.n.Add(1)
+	return nil
+}
+
+func TestRateLimiterSkipCacheHit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	cache, err := kcache.NewSqliteCache(filepath.Join(t.TempDir(), "c.sqlite"), "limiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	l := &countLimiter{}
+	c := NewRawCacheCrawler(cache, RawCacheWithRateLimiter(l))
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.n.Load() != 1 {
+		t.Fatalf("命中缓存不应该消耗令牌, 实际调用 %d 次", l.n.Load())
+	}
+}
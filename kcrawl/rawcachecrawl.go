@@ -1,7 +1,10 @@
 package kcrawl
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
 	"sort"
 	"time"
 
@@ -21,13 +24,26 @@ type RawCacheCrawler interface {
 
 	GetCache(url string, keys ...string) ([]byte, error, bool)
 	PostCache(url string, payload string, keys ...string) ([]byte, error, bool)
+	// DeleteCache 设置了 CacheKeyer 时，payload 为空当作 GET 请求，否则当作 POST 请求
 	DeleteCache(url string, payload string, keys ...string) error
+	DeleteRequestCache(r *Request) error
+
+	// 带 context 的版本，命中缓存时不会发起请求
+	GetCtx(ctx context.Context, url string, keys ...string) ([]byte, error)
+	PostCtx(ctx context.Context, url string, payload string, keys ...string) ([]byte, error)
+	PutCtx(ctx context.Context, url string, payload string, keys ...string) ([]byte, error)
+	Do(ctx context.Context, r *Request) ([]byte, error)
+	// DoResponse 和 Do 一样，但是返回完整的响应信息，命中缓存时 FromCache 为 true
+	DoResponse(ctx context.Context, r *Request) (*Response, error)
 }
 
 type rawCacheCrawler struct {
 	rawCrawler
 	cache             kcache.KCloseCache
 	recombineCacheKey func(key string) string
+	// 为空时使用 url + payload 作为缓存 key
+	cacheKeyer  CacheKeyer
+	cachePolicy CachePolicy
 }
 
 func NewRawCacheCrawler(cache kcache.KCloseCache, opts ...RawCacheCrawlerOption) RawCacheCrawler {
@@ -43,50 +59,15 @@ func NewRawCacheCrawler(cache kcache.KCloseCache, opts ...RawCacheCrawlerOption)
 }
 
 func (rcc *rawCacheCrawler) GetWithHeader(url string, header map[string]string, keys ...string) ([]byte, error) {
-	if data, err, ok := rcc.GetCache(url, keys...); ok {
-		return data, err
-	}
-	data, err := rcc.rawCrawler.GetWithHeader(url, header)
-	if err != nil {
-		return nil, err
-	}
-	key := rcc.CacheKey(url, "")
-	cd := combineCacheData(url, "", "GET", data)
-	d, _ := json.Marshal(&cd)
-	err = rcc.cache.Save(key, d)
-	return data, err
+	return rcc.Do(context.Background(), &Request{Method: "GET", URL: url, Header: header, Keys: keys})
 }
 
 func (rcc *rawCacheCrawler) Get(url string, keys ...string) ([]byte, error) {
 	return rcc.GetWithHeader(url, nil, keys...)
 }
 
-func combineCacheData(url string, payload string, method string, data []byte) cacheData {
-	cd := cacheData{
-		Data: string(data),
-		Request: request{
-			Method:  method,
-			URL:     url,
-			Payload: payload,
-		},
-		CreatedAt: time.Now(),
-	}
-	return cd
-}
-
 func (rcc *rawCacheCrawler) PostWithHeader(url string, payload string, header map[string]string, keys ...string) ([]byte, error) {
-	if data, err, ok := rcc.PostCache(url, payload, keys...); ok {
-		return data, err
-	}
-	data, err := rcc.rawCrawler.PostWithHeader(url, payload, header)
-	if err != nil {
-		return nil, err
-	}
-	key := rcc.CacheKey(url, payload)
-	cd := combineCacheData(url, payload, "POST", data)
-	d, _ := json.Marshal(&cd)
-	err = rcc.cache.Save(key, d)
-	return data, err
+	return rcc.Do(context.Background(), &Request{Method: "POST", URL: url, Payload: payload, Header: header, Keys: keys})
 }
 
 func (rcc *rawCacheCrawler) Post(url string, payload string, keys ...string) ([]byte, error) {
@@ -98,24 +79,109 @@ func (rcc *rawCacheCrawler) Put(url string, payload string, keys ...string) ([]b
 }
 
 func (rcc *rawCacheCrawler) PutWithHeader(url string, payload string, header map[string]string, keys ...string) ([]byte, error) {
-	if data, err, ok := rcc.PutCache(url, payload, keys...); ok {
-		return data, err
+	return rcc.Do(context.Background(), &Request{Method: "PUT", URL: url, Payload: payload, Header: header, Keys: keys})
+}
+
+func (rcc *rawCacheCrawler) GetCtx(ctx context.Context, url string, keys ...string) ([]byte, error) {
+	return rcc.Do(ctx, &Request{Method: "GET", URL: url, Keys: keys})
+}
+
+func (rcc *rawCacheCrawler) PostCtx(ctx context.Context, url string, payload string, keys ...string) ([]byte, error) {
+	return rcc.Do(ctx, &Request{Method: "POST", URL: url, Payload: payload, Keys: keys})
+}
+
+func (rcc *rawCacheCrawler) PutCtx(ctx context.Context, url string, payload string, keys ...string) ([]byte, error) {
+	return rcc.Do(ctx, &Request{Method: "PUT", URL: url, Payload: payload, Keys: keys})
+}
+
+func (rcc *rawCacheCrawler) Do(ctx context.Context, r *Request) ([]byte, error) {
+	res, err := rcc.DoResponse(ctx, r)
+	if err != nil {
+		return nil, err
 	}
+	return res.Body, nil
+}
 
-	data, err := rcc.rawCrawler.PutWithHeader(url, payload, header)
+// DoResponse 先查缓存，缓存不存在或者过期时再发起请求并写入缓存
+func (rcc *rawCacheCrawler) DoResponse(ctx context.Context, r *Request) (*Response, error) {
+	policy := &rcc.cachePolicy
+	if r.CachePolicy != nil {
+		policy = r.CachePolicy
+	}
+	key := rcc.requestKey(r)
+	cached, err := rcc.getCacheResponse(key, r)
 	if err != nil {
 		return nil, err
+	}
+	if cached != nil && policy.fresh(cached) {
+		return cached, nil
+	}
 
+	req := r
+	if cached != nil && policy.Revalidate {
+		header := conditionalHeader(cached)
+		if len(header) > 0 {
+			cr := *r
+			cr.Header = make(map[string]string, len(r.Header)+len(header))
+			for k, v := range r.Header {
+				cr.Header[k] = v
+			}
+			for k, v := range header {
+				cr.Header[k] = v
+			}
+			req = &cr
+		}
 	}
-	key := rcc.CacheKey(url, payload)
-	cd := combineCacheData(url, payload, "PUT", data)
+	res, err := rcc.rawCrawler.DoResponse(ctx, req)
+	if err != nil {
+		var se *StatusError
+		if cached != nil && req != r && errors.As(err, &se) && se.StatusCode == http.StatusNotModified {
+			// 内容没有变化，刷新缓存时间
+			for _, h := range []string{"ETag", "Last-Modified"} {
+				if v := se.Header.Get(h); v != "" {
+					cached.Header.Set(h, v)
+				}
+			}
+			cached.CachedAt = time.Now()
+			return cached, rcc.saveCache(key, cached, policy)
+		}
+		if cached != nil && policy.StaleIfError {
+			return cached, nil
+		}
+		return nil, err
+	}
+	return res, rcc.saveCache(key, res, policy)
+}
+
+// saveCache 缓存实现了 kcache.KCacheWithTTL 时按策略设置过期时间
+func (rcc *rawCacheCrawler) saveCache(key string, res *Response, policy *CachePolicy) error {
+	cd := newCacheData(res)
 	d, _ := json.Marshal(&cd)
-	err = rcc.cache.Save(key, d)
-	return data, err
+	if ttl := policy.storeTTL(); ttl > 0 {
+		if tc, ok := rcc.cache.(kcache.KCacheWithTTL); ok {
+			return tc.SaveWithTTL(key, d, ttl)
+		}
+	}
+	return rcc.cache.Save(key, d)
 }
 
 func (rcc *rawCacheCrawler) GetCache(url string, keys ...string) ([]byte, error, bool) {
-	return rcc.getCache(url, "", keys...)
+	return rcc.getCache(&Request{Method: "GET", URL: url, Keys: keys})
+}
+
+// requestKey 指定了 keys 时使用 keys，设置了 CacheKeyer 时使用 CacheKeyer，否则使用 url + payload
+func (rcc *rawCacheCrawler) requestKey(r *Request) string {
+	if len(r.Keys) > 0 || rcc.cacheKeyer == nil {
+		return rcc.CacheKey(r.URL, r.Payload, r.Keys...)
+	}
+	// 合并 crawler 的公共 header，CacheKeyer 看到的是实际发送的 header
+	kr := *r
+	kr.Header = mergeHeader(rcc.header, r.Header)
+	key := rcc.cacheKeyer.CacheKey(&kr)
+	if rcc.recombineCacheKey != nil {
+		return rcc.recombineCacheKey(key)
+	}
+	return key
 }
 
 func (rcc *rawCacheCrawler) CacheKey(url string, payload string, keys ...string) string {
@@ -136,32 +202,45 @@ func (rcc *rawCacheCrawler) CacheKey(url string, payload string, keys ...string)
 }
 
 func (rcc *rawCacheCrawler) PostCache(url string, payload string, keys ...string) ([]byte, error, bool) {
-	return rcc.getCache(url, payload, keys...)
+	return rcc.getCache(&Request{Method: "POST", URL: url, Payload: payload, Keys: keys})
 }
 
 // PutCache
 func (rcc *rawCacheCrawler) PutCache(url string, payload string, keys ...string) ([]byte, error, bool) {
-	return rcc.getCache(url, payload, keys...)
+	return rcc.getCache(&Request{Method: "PUT", URL: url, Payload: payload, Keys: keys})
 }
 
-func (rcc *rawCacheCrawler) getCache(url string, payload string, keys ...string) ([]byte, error, bool) {
-	key := rcc.CacheKey(url, payload, keys...)
-	data, err := rcc.cache.Get(key)
-	if err != nil {
+func (rcc *rawCacheCrawler) getCache(r *Request) ([]byte, error, bool) {
+	res, err := rcc.getCacheResponse(rcc.requestKey(r), r)
+	if err != nil || res == nil {
 		return nil, err, false
 	}
+	return res.Body, nil, true
+}
+
+// getCacheResponse 没有缓存时返回 nil, nil
+func (rcc *rawCacheCrawler) getCacheResponse(key string, r *Request) (*Response, error) {
+	data, err := rcc.cache.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
 	cd := cacheData{}
 	err = json.Unmarshal(data, &cd)
-	if err != nil {
-		return data, nil, data != nil
-	}
-	if cd.Data == "" {
-		return data, nil, data != nil
+	if err != nil || cd.Data == "" {
+		// 兼容直接保存 body 的旧数据
+		cd = cacheData{Data: string(data)}
 	}
-	return []byte(cd.Data), nil, cd.Data != ""
+	return cd.response(r), nil
 }
 
 func (rcc *rawCacheCrawler) DeleteCache(url string, payload string, keys ...string) error {
-	key := rcc.CacheKey(url, payload, keys...)
-	return rcc.cache.Delete(key)
+	method := "GET"
+	if payload != "" {
+		method = "POST"
+	}
+	return rcc.DeleteRequestCache(&Request{Method: method, URL: url, Payload: payload, Keys: keys})
+}
+
+func (rcc *rawCacheCrawler) DeleteRequestCache(r *Request) error {
+	return rcc.cache.Delete(rcc.requestKey(r))
 }
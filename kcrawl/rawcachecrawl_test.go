@@ -0,0 +1,212 @@
+package kcrawl
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kevin-zx/kbase/kcache"
+)
+
+func newTestCache(t *testing.T) kcache.KCloseCache {
+	t.Helper()
+	cache, err := kcache.NewSqliteCache(filepath.Join(t.TempDir(), "c.sqlite"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestRawCacheCrawlerResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	c := NewRawCacheCrawler(newTestCache(t))
+	ctx := context.Background()
+	live, err := c.DoResponse(ctx, &Request{URL: ts.URL + "/old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := c.DoResponse(ctx, &Request{URL: ts.URL + "/old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.FromCache || !cached.FromCache {
+		t.Fatalf("FromCache 不对: live=%v cached=%v", live.FromCache, cached.FromCache)
+	}
+	for _, res := range []*Response{live, cached} {
+		if res.StatusCode != 200 || res.URL != ts.URL+"/new" || string(res.Body) != "hello" ||
+			res.Header.Get("Content-Type") != "text/plain" || res.Header.Get("Last-Modified") == "" {
+			t.Fatalf("响应信息不完整: %+v", res)
+		}
+	}
+}
+
+func TestRawCacheCrawlerCachePolicy(t *testing.T) {
+	var hits, notModified atomic.Int32
+	var down atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("v1"))
+	}))
+	defer ts.Close()
+
+	c := NewRawCacheCrawler(newTestCache(t), RawCacheWithCachePolicy(CachePolicy{
+		MaxAge:       50 * time.Millisecond,
+		Revalidate:   true,
+		StaleIfError: true,
+	}))
+	ctx := context.Background()
+	get := func() *Response {
+		t.Helper()
+		res, err := c.DoResponse(ctx, &Request{URL: ts.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Body) != "v1" {
+			t.Fatalf("期望 v1, 得到 %q", res.Body)
+		}
+		return res
+	}
+
+	get()
+	if res := get(); !res.FromCache || hits.Load() != 1 {
+		t.Fatalf("有效期内应该直接使用缓存, hits=%d", hits.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if res := get(); !res.FromCache || notModified.Load() != 1 {
+		t.Fatalf("过期后应该重新验证并使用缓存, 304 次数=%d", notModified.Load())
+	}
+	if get(); hits.Load() != 2 {
+		t.Fatalf("304 之后缓存应该重新计时, hits=%d", hits.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	down.Store(true)
+	if res := get(); !res.FromCache {
+		t.Fatal("请求失败时应该返回过期的缓存")
+	}
+
+	// 单次请求覆盖策略
+	_, err := c.DoResponse(ctx, &Request{URL: ts.URL, CachePolicy: &CachePolicy{MaxAge: time.Millisecond}})
+	if err == nil {
+		t.Fatal("没有 StaleIfError 时应该返回错误")
+	}
+}
+
+// 304 没有响应体，带着 Content-Encoding 时不应该解压，之前会返回 gzip 的 EOF 错误
+func TestRawCacheCrawlerNotModifiedWithEncoding(t *testing.T) {
+	var notModified atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte("hello"))
+		gw.Close()
+	}))
+	defer ts.Close()
+
+	c := NewRawCacheCrawler(newTestCache(t),
+		RawCacheWithHeader(map[string]string{"Accept-Encoding": "gzip"}),
+		RawCacheWithCachePolicy(CachePolicy{MaxAge: time.Nanosecond, Revalidate: true}))
+	for i := 0; i < 2; i++ {
+		data, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "hello" {
+			t.Fatalf("期望 hello, 得到 %q", data)
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Fatalf("第二次请求应该返回 304, 实际 %d 次", notModified.Load())
+	}
+}
+
+// 指定了 keys 时读写缓存都使用 keys，之前写入时忽略 keys，导致带 keys 的请求永远不会命中缓存
+func TestRawCacheCrawlerKeys(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("v" + r.URL.Query().Get("v")))
+	}))
+	defer ts.Close()
+
+	c := NewRawCacheCrawler(newTestCache(t))
+	if _, err := c.Get(ts.URL+"/?v=1", "b", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, ok := c.GetCache(ts.URL+"/?v=2", "a", "b"); !ok || string(data) != "v1" {
+		t.Fatalf("相同的 keys 应该命中缓存: %q %v", data, ok)
+	}
+	if _, _, ok := c.GetCache(ts.URL + "/?v=1"); ok {
+		t.Fatal("没有 keys 时不应该命中按 keys 保存的缓存")
+	}
+	if data, err := c.Get(ts.URL+"/?v=3", "a", "b"); err != nil || string(data) != "v1" || hits.Load() != 1 {
+		t.Fatalf("应该使用缓存: %q %v, 请求了 %d 次", data, err, hits.Load())
+	}
+	if err := c.DeleteCache(ts.URL, "", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.GetCache(ts.URL+"/?v=1", "a", "b"); ok {
+		t.Fatal("删除之后不应该命中缓存")
+	}
+}
+
+func TestCacheCrawlerOnlyCaches200(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/accepted" {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+
+	c := &cacheCrawler{cacheDir: kcache.NewFileCache(t.TempDir())}
+	var v struct{ OK bool }
+	err := c.Get(ts.URL+"/accepted", &v)
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusAccepted {
+		t.Fatalf("202 应该返回 StatusError: %v", err)
+	}
+	if ok, _ := c.GetTryCache([]string{ts.URL + "/accepted"}, &v); ok {
+		t.Fatal("202 的响应不应该写入缓存")
+	}
+	if err = c.Get(ts.URL+"/ok", &v); err != nil || !v.OK {
+		t.Fatalf("200 应该成功: %v %+v", err, v)
+	}
+	if ok, _ := c.GetTryCache([]string{ts.URL + "/ok"}, &v); !ok {
+		t.Fatal("200 的响应应该写入缓存")
+	}
+}
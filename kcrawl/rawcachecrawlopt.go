@@ -37,3 +37,67 @@ func RawCacheWithHasDeflatCompressed(has bool) RawCacheCrawlerOption {
 		c.hasDeflateCompressed = has
 	}
 }
+
+// RawCacheWithRetry 请求失败时按 policy 重试，命中缓存时不会触发
+func RawCacheWithRetry(policy RetryPolicy) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.retry = &policy
+	}
+}
+
+// RawCacheWithRateLimiter 使用 limiter 控制请求频率，代替固定的 intervalSeconds，命中缓存不消耗令牌
+func RawCacheWithRateLimiter(limiter RateLimiter) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.limiter = limiter
+	}
+}
+
+// RawCacheWithCharsetDecode 按 Content-Type 或者 <meta charset> 把 body 转成 utf-8，缓存的是转换后的数据
+func RawCacheWithCharsetDecode(decode bool) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.decodeCharset = decode
+	}
+}
+
+// RawCacheWithSession 使用 session 保存和发送 cookie
+func RawCacheWithSession(session *Session) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.jar = session
+	}
+}
+
+// RawCacheWithCachePolicy 设置缓存的新鲜度策略，单次请求可以用 Request.CachePolicy 覆盖
+func RawCacheWithCachePolicy(policy CachePolicy) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.cachePolicy = policy
+	}
+}
+
+// RawCacheWithCacheKeyer 使用 keyer 生成缓存 key，比如 NewFingerprintKeyer("Authorization")。
+// 请求指定了 keys 时仍然使用 keys，RawCacheCrawlerWithRecombineCacheKey 会在 keyer 之后处理
+func RawCacheWithCacheKeyer(keyer CacheKeyer) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.cacheKeyer = keyer
+	}
+}
+
+// RawCacheWithRobots 遵守 robots.txt，被禁止的请求返回 ErrDisallowedByRobots，已经缓存的数据不受影响
+func RawCacheWithRobots(policy *RobotsPolicy) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.robots = policy
+	}
+}
+
+// RawCacheWithRecorder 通过 rec 记录或者回放请求，用于离线测试
+func RawCacheWithRecorder(rec *Recorder) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.recorder = rec
+	}
+}
+
+// RawCacheWithValidators 校验失败的响应不会缓存，设置了重试时会重试
+func RawCacheWithValidators(validators ...Validator) RawCacheCrawlerOption {
+	return func(c *rawCacheCrawler) {
+		c.validators = append(c.validators, validators...)
+	}
+}
@@ -2,10 +2,13 @@ package kcrawl
 
 import (
 	"compress/flate"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +20,36 @@ type RawCrawler interface {
 	GetWithHeader(url string, header map[string]string) ([]byte, error)
 	PostWithHeader(url string, payload string, header map[string]string) ([]byte, error)
 	PutWithHeader(url string, payload string, header map[string]string) ([]byte, error)
+
+	// 带 context 的版本，取消 context 会同时中断正在进行的请求和请求之间的等待
+	GetCtx(ctx context.Context, url string) ([]byte, error)
+	PostCtx(ctx context.Context, url string, payload string) ([]byte, error)
+	PutCtx(ctx context.Context, url string, payload string) ([]byte, error)
+	Do(ctx context.Context, r *Request) ([]byte, error)
+	// DoResponse 和 Do 一样，但是返回完整的响应信息
+	DoResponse(ctx context.Context, r *Request) (*Response, error)
+}
+
+// Request 描述一次请求，是各个 crawler 的统一入口
+type Request struct {
+	Method  string
+	URL     string
+	Payload string
+	// 本次请求额外的 header，会覆盖 crawler 上的同名 header
+	Header map[string]string
+	// 只对 RawCacheCrawler 生效，用于自定义缓存 key
+	Keys []string
+	// 只对 RawCacheCrawler 生效，覆盖 crawler 的缓存策略
+	CachePolicy *CachePolicy
+	// 本次请求额外的校验，在 crawler 的校验之后执行，失败的响应不会缓存
+	Validators []Validator
+}
+
+func (r *Request) method() string {
+	if r.Method == "" {
+		return "GET"
+	}
+	return r.Method
 }
 
 type rawCrawler struct {
@@ -25,17 +58,49 @@ type rawCrawler struct {
 	intervalSeconds      int
 	proxyPool            ProxyPool
 	hasDeflateCompressed bool
+	// 为空时不重试
+	retry *RetryPolicy
+	// 是否按 Content-Type 或者 <meta charset> 把 body 转成 utf-8
+	decodeCharset bool
+	// 为空时不保存 cookie
+	jar http.CookieJar
+	// 设置后遵守 robots.txt
+	robots *RobotsPolicy
+	// 设置后在请求前限速，不再在响应后固定等待 intervalSeconds
+	limiter RateLimiter
+	// 设置后记录或者回放请求
+	recorder *Recorder
+	// 响应的校验，任意一个失败时请求失败，可以重试
+	validators []Validator
+	// 代理池没有实现 proxyTransporter 时，每个代理的 Transport
+	transports proxyTransports
 }
 
-func NewRawCrawler(intervalSeconds int, header map[string]string) RawCrawler {
+func NewRawCrawler(intervalSeconds int, header map[string]string, opts ...RawCrawlerOption) RawCrawler {
 	httpHeader := http.Header{}
 	for k, v := range header {
 		httpHeader.Add(k, v)
 	}
-	return &rawCrawler{
+	c := &rawCrawler{
 		intervalSeconds: intervalSeconds,
 		header:          httpHeader,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// StatusError 响应状态码不是 2xx 时返回
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code error: %d %s and body is: %s", e.StatusCode, e.Status, string(e.Body))
 }
 
 func (c *rawCrawler) GetWithHeader(url string, header map[string]string) ([]byte, error) {
@@ -63,35 +128,105 @@ func (c *rawCrawler) PutWithHeader(url string, payload string, header map[string
 	return c.Request(url, payload, "PUT", header)
 }
 
+func (c *rawCrawler) GetCtx(ctx context.Context, url string) ([]byte, error) {
+	return c.Do(ctx, &Request{Method: "GET", URL: url})
+}
+
+func (c *rawCrawler) PostCtx(ctx context.Context, url string, payload string) ([]byte, error) {
+	return c.Do(ctx, &Request{Method: "POST", URL: url, Payload: payload})
+}
+
+func (c *rawCrawler) PutCtx(ctx context.Context, url string, payload string) ([]byte, error) {
+	return c.Do(ctx, &Request{Method: "PUT", URL: url, Payload: payload})
+}
+
 func (c *rawCrawler) Request(url string, payload string, method string, header map[string]string) ([]byte, error) {
+	return c.Do(context.Background(), &Request{Method: method, URL: url, Payload: payload, Header: header})
+}
+
+func (c *rawCrawler) Do(ctx context.Context, r *Request) ([]byte, error) {
+	res, err := c.DoResponse(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// DoResponse 发起请求，配置了重试策略时按策略重试
+func (c *rawCrawler) DoResponse(ctx context.Context, r *Request) (*Response, error) {
+	if c.retry == nil {
+		return c.doOnce(ctx, r)
+	}
+	for attempt := 1; ; attempt++ {
+		res, err := c.doOnce(ctx, r)
+		if err == nil || attempt >= c.retry.MaxAttempts || !c.retry.Retryable(err) {
+			return res, err
+		}
+		if werr := sleepCtx(ctx, c.retry.Backoff(attempt, err)); werr != nil {
+			return nil, werr
+		}
+	}
+}
+
+func (c *rawCrawler) doOnce(ctx context.Context, r *Request) (*Response, error) {
 	var payloadr io.Reader
-	if payload != "" {
-		payloadr = strings.NewReader(payload)
+	if r.Payload != "" {
+		payloadr = strings.NewReader(r.Payload)
 	}
-	client := &http.Client{}
+	client := &http.Client{Jar: c.jar}
+	var proxy *url.URL
 	if c.proxyPool != nil {
-		proxy := c.proxyPool.GetHttpProxy()
+		// 优先使用 http 代理，没有时使用 socks5 代理
+		proxy = c.proxyPool.GetHttpProxy()
+		if proxy == nil {
+			proxy = c.proxyPool.GetSock5Proxy()
+		}
 		if proxy != nil {
-			client.Transport = &http.Transport{
-				Proxy: http.ProxyURL(proxy),
-			}
+			client.Transport = c.proxyTransport(proxy)
 		}
 	}
+	if c.recorder != nil {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		client.Transport = c.recorder.wrap(next)
+	}
 
-	req, err := http.NewRequest(method, url, payloadr)
+	req, err := http.NewRequestWithContext(ctx, r.method(), r.URL, payloadr)
 	if err != nil {
 		return nil, err
 	}
-	req.Header = c.header
-	for k, v := range header {
+	// clone 一份，避免单次请求的 header 污染 crawler 的公共 header
+	req.Header = c.header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for k, v := range r.Header {
 		req.Header.Set(k, v)
 	}
+	if c.robots != nil {
+		err = c.robots.check(ctx, req.URL, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.limiter != nil {
+		err = c.limiter.Wait(ctx, req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
 	res, err := client.Do(req)
 	if err != nil {
+		c.reportProxy(ctx, proxy, err)
 		return nil, err
 	}
 	defer res.Body.Close()
-	time.Sleep(time.Duration(c.intervalSeconds) * time.Second)
+	if res.StatusCode == http.StatusProxyAuthRequired {
+		c.reportProxy(ctx, proxy, fmt.Errorf("proxy auth required: %s", res.Status))
+	}
 	var body []byte
 	// 如果有 deflate 压缩，需要解压
 	if c.hasDeflateCompressed {
@@ -103,13 +238,140 @@ func (c *rawCrawler) Request(url string, payload string, method string, header m
 		}
 	} else {
 		body, err = io.ReadAll(res.Body)
+		if err != nil {
+			c.reportProxy(ctx, proxy, err)
+			return nil, err
+		}
+		// 没有手动设置 Accept-Encoding 时 net/http 会自动处理 gzip，其它情况在这里解压。
+		// 304、204 和 HEAD 的响应没有响应体，不需要解压
+		if ce := res.Header.Get("Content-Encoding"); ce != "" && len(body) > 0 {
+			body, err = decodeContentEncoding(body, ce)
+			if err != nil {
+				return nil, err
+			}
+			res.Header.Del("Content-Encoding")
+			res.Header.Del("Content-Length")
+		}
+	}
+	if c.decodeCharset {
+		body, err = toUTF8(body, res.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	duration := time.Since(start)
+	if res.StatusCode != http.StatusProxyAuthRequired {
+		c.reportProxy(ctx, proxy, nil)
+	}
+	if c.limiter == nil {
+		err = sleepCtx(ctx, time.Duration(c.intervalSeconds)*time.Second)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	if res.StatusCode >= 300 || res.StatusCode < 200 {
-		return nil, fmt.Errorf("status code error: %d %s and body is: %s", res.StatusCode, res.Status, string(body))
+		return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: body}
+	}
+	response := &Response{
+		Request:    r,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+		URL:        res.Request.URL.String(),
+		Duration:   duration,
+		Body:       body,
+	}
+	err = validate(response, c.validators)
+	if err != nil {
+		return nil, err
+	}
+	err = validate(response, r.Validators)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// mergeHeader 合并公共 header 和单次请求的 header，单次请求的优先
+func mergeHeader(base http.Header, header map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(header))
+	for k := range base {
+		merged[k] = base.Get(k)
+	}
+	for k, v := range header {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	return merged
+}
+
+// reportProxy 把代理的使用结果报告给实现了 ProxyFeedback 的代理池，err 为空表示成功。
+// ctx 取消或者超时导致的错误不是代理的问题，不报告
+func (c *rawCrawler) reportProxy(ctx context.Context, proxy *url.URL, err error) {
+	if proxy == nil || (err != nil && ctx.Err() != nil) {
+		return
+	}
+	fb, ok := c.proxyPool.(ProxyFeedback)
+	if !ok {
+		return
+	}
+	if err != nil {
+		fb.ReportFailure(proxy, err)
+		return
+	}
+	fb.ReportSuccess(proxy)
+}
+
+// proxyTransports 代理地址 -> *http.Transport，同一个代理的请求复用连接，零值可以直接使用
+type proxyTransports struct {
+	m sync.Map
+}
+
+func (t *proxyTransports) get(proxy *url.URL) *http.Transport {
+	key := proxy.String()
+	if tr, ok := t.m.Load(key); ok {
+		return tr.(*http.Transport)
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = http.ProxyURL(proxy)
+	actual, loaded := t.m.LoadOrStore(key, tr)
+	if loaded {
+		tr.CloseIdleConnections()
+	}
+	return actual.(*http.Transport)
+}
+
+// remove 删除代理的 Transport 并关闭它的空闲连接
+func (t *proxyTransports) remove(proxy *url.URL) {
+	if tr, ok := t.m.LoadAndDelete(proxy.String()); ok {
+		tr.(*http.Transport).CloseIdleConnections()
+	}
+}
+
+// proxyTransporter 代理池可以实现这个接口自己管理代理的 Transport，移除代理时关闭它的连接
+type proxyTransporter interface {
+	proxyTransport(proxy *url.URL) *http.Transport
+}
+
+// proxyTransport 代理池管理 Transport 时使用代理池的，否则使用 crawler 自己的
+func (c *rawCrawler) proxyTransport(proxy *url.URL) *http.Transport {
+	if pt, ok := c.proxyPool.(proxyTransporter); ok {
+		return pt.proxyTransport(proxy)
+	}
+	return c.transports.get(proxy)
+}
+
+// sleepCtx 等待 d 时间，context 被取消时提前返回
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
-	return body, nil
 }
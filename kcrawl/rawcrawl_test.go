@@ -0,0 +1,48 @@
+package kcrawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRawCrawlerCtxCancel(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	// 请求挂起时取消
+	c := NewRawCrawler(0, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := c.GetCtx(ctx, ts.URL+"/hang")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望 DeadlineExceeded, 得到 %v", err)
+	}
+
+	// 请求之间的等待也会被取消
+	c = NewRawCrawler(60, nil)
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	start := time.Now()
+	_, err = c.GetCtx(ctx2, ts.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望 DeadlineExceeded, 得到 %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("等待没有被取消")
+	}
+}
@@ -0,0 +1,59 @@
+package kcrawl
+
+type RawCrawlerOption func(c *rawCrawler)
+
+// RawWithRetry 请求失败时按 policy 重试
+func RawWithRetry(policy RetryPolicy) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.retry = &policy
+	}
+}
+
+// RawWithRateLimiter 使用 limiter 控制请求频率，代替固定的 intervalSeconds
+func RawWithRateLimiter(limiter RateLimiter) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.limiter = limiter
+	}
+}
+
+// RawWithCharsetDecode 按 Content-Type 或者 <meta charset> 把 body 转成 utf-8，适用于 GBK 之类的站点
+func RawWithCharsetDecode(decode bool) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.decodeCharset = decode
+	}
+}
+
+// RawWithSession 使用 session 保存和发送 cookie
+func RawWithSession(session *Session) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.jar = session
+	}
+}
+
+// RawWithProxyPool 每次请求从 proxyPool 取一个代理，proxyPool 实现了 ProxyFeedback 时会报告代理的使用结果
+func RawWithProxyPool(proxyPool ProxyPool) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.proxyPool = proxyPool
+	}
+}
+
+// RawWithRobots 遵守 robots.txt，被禁止的请求返回 ErrDisallowedByRobots
+func RawWithRobots(policy *RobotsPolicy) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.robots = policy
+	}
+}
+
+// RawWithRecorder 通过 rec 记录或者回放请求，用于离线测试
+func RawWithRecorder(rec *Recorder) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.recorder = rec
+	}
+}
+
+// RawWithValidators 校验失败的响应返回 *ValidationError，设置了重试时会重试
+func RawWithValidators(validators ...Validator) RawCrawlerOption {
+	return func(c *rawCrawler) {
+		c.validators = append(c.validators, validators...)
+	}
+}
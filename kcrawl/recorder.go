@@ -0,0 +1,372 @@
+package kcrawl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoRecording 回放模式下请求没有对应的记录
+var ErrNoRecording = errors.New("kcrawl: no recorded response")
+
+// RecordMode Recorder 的工作模式
+type RecordMode int
+
+const (
+	// RecordModeRecord 发起真实请求并记录
+	RecordModeRecord RecordMode = iota
+	// RecordModeReplay 只回放记录，没有记录的请求返回 ErrNoRecording，不会访问网络
+	RecordModeReplay
+)
+
+// Recorder 记录和回放请求的 http.RoundTripper，让 crawler 可以离线测试。
+// 记录可以保存为目录（每个请求一个 json 文件）或者 HAR 文件，请求按 method、规范化之后的 url 和 body 匹配，
+// 同一个请求记录了多次时回放最后一次
+type Recorder struct {
+	mode RecordMode
+	// dir 和 harPath 只有一个不为空
+	dir     string
+	harPath string
+	// 记录模式下实际发送请求的 RoundTripper，默认 http.DefaultTransport
+	next http.RoundTripper
+	// 为 true 时记录中保留 sensitiveHeaders
+	keepSensitive bool
+
+	mu      sync.Mutex
+	har     harLog
+	entries map[string]*harEntry
+}
+
+// NewDirRecorder 记录保存在 dir 目录下，每个请求一个 json 文件
+func NewDirRecorder(dir string, mode RecordMode) (*Recorder, error) {
+	rec := &Recorder{mode: mode, dir: dir, entries: make(map[string]*harEntry)}
+	if mode == RecordModeRecord {
+		return rec, os.MkdirAll(dir, 0755)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		e := &harEntry{}
+		if err = json.Unmarshal(data, e); err != nil {
+			return nil, fmt.Errorf("load recording %s: %w", f, err)
+		}
+		rec.entries[strings.TrimSuffix(filepath.Base(f), ".json")] = e
+	}
+	return rec, nil
+}
+
+// NewHARRecorder 记录保存在 HAR 文件中，可以用浏览器的开发者工具查看；回放时也可以直接使用浏览器导出的 HAR
+func NewHARRecorder(path string, mode RecordMode) (*Recorder, error) {
+	rec := &Recorder{
+		mode:    mode,
+		harPath: path,
+		entries: make(map[string]*harEntry),
+		har:     harLog{Version: "1.2", Creator: harCreator{Name: "kcrawl", Version: "1.0"}},
+	}
+	if mode == RecordModeRecord {
+		return rec, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hf harFile
+	if err = json.Unmarshal(data, &hf); err != nil {
+		return nil, fmt.Errorf("load har %s: %w", path, err)
+	}
+	for i := range hf.Log.Entries {
+		e := &hf.Log.Entries[i]
+		rec.entries[recordKey(e.Request.Method, e.Request.URL, e.Request.body())] = e
+	}
+	return rec, nil
+}
+
+// SetTransport 设置记录模式下实际发送请求的 RoundTripper
+func (rec *Recorder) SetTransport(next http.RoundTripper) {
+	rec.next = next
+}
+
+// SetKeepSensitiveHeaders 默认记录中不保存 Cookie、Authorization、Set-Cookie 等 header，
+// 避免把登录信息提交到测试数据中；keep 为 true 时原样保存，回放时 Set-Cookie 也会生效
+func (rec *Recorder) SetKeepSensitiveHeaders(keep bool) {
+	rec.keepSensitive = keep
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := rec.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return rec.roundTrip(req, next)
+}
+
+// wrap crawler 使用，记录模式下通过 next 发送请求，这样代理等设置仍然生效
+func (rec *Recorder) wrap(next http.RoundTripper) http.RoundTripper {
+	if rec.next != nil {
+		next = rec.next
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return rec.roundTrip(req, next)
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func recordKey(method string, url string, body string) string {
+	return (&FingerprintKeyer{}).CacheKey(&Request{Method: method, URL: url, Payload: body})
+}
+
+func (rec *Recorder) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	key := recordKey(req.Method, req.URL.String(), string(reqBody))
+
+	if rec.mode == RecordModeReplay {
+		rec.mu.Lock()
+		e, ok := rec.entries[key]
+		rec.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoRecording, req.Method, req.URL)
+		}
+		return e.Response.httpResponse(req)
+	}
+
+	start := time.Now()
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	e := newHAREntry(req, reqBody, res, resBody, start, !rec.keepSensitive)
+	if err = rec.save(key, e); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (rec *Recorder) save(key string, e *harEntry) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries[key] = e
+	if rec.dir != "" {
+		data, err := json.MarshalIndent(e, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(rec.dir, key+".json"), data, 0644)
+	}
+	rec.har.Entries = append(rec.har.Entries, *e)
+	data, err := json.MarshalIndent(&harFile{Log: rec.har}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(rec.harPath, data, 0644)
+}
+
+// 下面是 HAR 1.2 中用到的部分，http://www.softwareishard.com/blog/har-12-spec/
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+func (r *harRequest) body() string {
+	if r.PostData == nil {
+		return ""
+	}
+	return r.PostData.Text
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// sensitiveHeaders 默认不记录的 header
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// harHeaders redact 为 true 时去掉 sensitiveHeaders
+func harHeaders(h http.Header, redact bool) []harNameValue {
+	nvs := []harNameValue{}
+	for k, vs := range h {
+		if redact && slices.Contains(sensitiveHeaders, http.CanonicalHeaderKey(k)) {
+			continue
+		}
+		for _, v := range vs {
+			nvs = append(nvs, harNameValue{Name: k, Value: v})
+		}
+	}
+	return nvs
+}
+
+func newHAREntry(req *http.Request, reqBody []byte, res *http.Response, resBody []byte, start time.Time, redact bool) *harEntry {
+	elapsed := float64(time.Since(start).Microseconds()) / 1000
+	e := &harEntry{
+		StartedDateTime: start,
+		Time:            elapsed,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header, redact),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: harResponse{
+			Status:      res.StatusCode,
+			StatusText:  http.StatusText(res.StatusCode),
+			HTTPVersion: res.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(res.Header, redact),
+			Content: harContent{
+				Size:     len(resBody),
+				MimeType: res.Header.Get("Content-Type"),
+				// body 可能是压缩过的或者非 utf-8 的，统一用 base64 保存
+				Text:     base64.StdEncoding.EncodeToString(resBody),
+				Encoding: "base64",
+			},
+			RedirectURL: res.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(resBody),
+		},
+		Timings: harTimings{Wait: elapsed},
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			e.Request.QueryString = append(e.Request.QueryString, harNameValue{Name: k, Value: v})
+		}
+	}
+	if len(reqBody) > 0 {
+		e.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(reqBody)}
+	}
+	return e
+}
+
+func (r *harResponse) httpResponse(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Content.Text)
+	if r.Content.Encoding == "base64" {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(r.Content.Text)
+		if err != nil {
+			return nil, err
+		}
+	}
+	header := http.Header{}
+	for _, nv := range r.Headers {
+		header.Add(nv.Name, nv.Value)
+	}
+	// 浏览器导出的 HAR 中 body 已经解压过了
+	if r.Content.Encoding != "base64" {
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+	}
+	status := fmt.Sprintf("%d %s", r.Status, r.StatusText)
+	return &http.Response{
+		Status:        status,
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
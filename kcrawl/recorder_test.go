@@ -0,0 +1,99 @@
+package kcrawl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderReplay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Test", "1")
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(body)))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	stores := map[string]func(mode RecordMode) (*Recorder, error){
+		"dir": func(mode RecordMode) (*Recorder, error) { return NewDirRecorder(filepath.Join(dir, "rec"), mode) },
+		"har": func(mode RecordMode) (*Recorder, error) { return NewHARRecorder(filepath.Join(dir, "rec.har"), mode) },
+	}
+	reqs := []*Request{
+		{URL: ts.URL + "/old"},
+		{Method: "POST", URL: ts.URL + "/post", Payload: `{"a":1}`},
+	}
+	for name, open := range stores {
+		rec, err := open(RecordModeRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewRawCrawler(0, nil, RawWithRecorder(rec))
+		want := make([]*Response, len(reqs))
+		for i, r := range reqs {
+			want[i], err = c.DoResponse(context.Background(), r)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+		}
+
+		rec, err = open(RecordModeReplay)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		c = NewRawCrawler(0, nil, RawWithRecorder(rec))
+		for i, r := range reqs {
+			got, err := c.DoResponse(context.Background(), r)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			if string(got.Body) != string(want[i].Body) || got.URL != want[i].URL || got.Header.Get("X-Test") != "1" {
+				t.Fatalf("%s: 回放结果不一致 %q %s", name, got.Body, got.URL)
+			}
+		}
+		_, err = c.Get(ts.URL + "/missing")
+		if !errors.Is(err, ErrNoRecording) {
+			t.Fatalf("%s: 期望 ErrNoRecording, 得到 %v", name, err)
+		}
+	}
+}
+
+func TestRecorderRedactsSensitiveHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "cookie-secret"})
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	header := map[string]string{"Authorization": "Bearer token-secret", "Cookie": "a=cookie-secret"}
+	for _, keep := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "rec.har")
+		rec, err := NewHARRecorder(path, RecordModeRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.SetKeepSensitiveHeaders(keep)
+		if _, err = NewRawCrawler(0, header, RawWithRecorder(rec)).Get(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"token-secret", "cookie-secret"} {
+			if strings.Contains(string(data), secret) != keep {
+				t.Fatalf("keep=%v: 记录中 %s 的处理不对:\n%s", keep, secret, data)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package kcrawl
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response 一次请求的完整响应，不论是实时请求还是命中缓存都返回同样的结构
+type Response struct {
+	Request    *Request
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// 跟随跳转之后的最终地址
+	URL string
+	// 从发出请求到读完 body 的耗时
+	Duration time.Duration
+	Body     []byte
+
+	// 是否来自缓存，CachedAt 为写入缓存的时间
+	FromCache bool
+	CachedAt  time.Time
+}
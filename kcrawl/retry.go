@@ -0,0 +1,135 @@
+package kcrawl
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 请求失败时的重试策略
+type RetryPolicy struct {
+	// 最多尝试次数（包含第一次），<=1 表示不重试
+	MaxAttempts int
+	// 第一次重试前的等待时间，之后每次翻倍
+	BaseDelay time.Duration
+	// 单次等待时间的上限，0 表示不限制
+	MaxDelay time.Duration
+	// 抖动比例，取值 0~1，实际等待时间落在 [d*(1-Jitter), d] 之间
+	Jitter float64
+	// 需要重试的状态码，为空时重试 429 和 5xx
+	RetryStatuses []int
+	// 是否遵循响应中的 Retry-After，等待时间同样不超过 MaxDelay
+	RespectRetryAfter bool
+	// 自定义错误分类，返回 true 表示可以重试；为空时使用 DefaultRetryClassify
+	Classify func(err error) bool
+}
+
+// DefaultRetryPolicy 最多请求 3 次，从 1 秒开始指数退避
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       3,
+		BaseDelay:         time.Second,
+		MaxDelay:          30 * time.Second,
+		Jitter:            0.2,
+		RespectRetryAfter: true,
+	}
+}
+
+// DefaultRetryClassify 默认的错误分类：context 取消、robots.txt 禁止和没有回放记录不重试，状态码错误只重试 429 和 5xx，其它错误（网络错误等）都重试
+func DefaultRetryClassify(err error) bool {
+	return defaultRetryClassify(err, nil)
+}
+
+func defaultRetryClassify(err error, statuses []int) bool {
+	if isFinalError(err) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		if len(statuses) > 0 {
+			return slices.Contains(statuses, se.StatusCode)
+		}
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return true
+}
+
+// Retryable 判断 err 是否可以重试
+func (p RetryPolicy) Retryable(err error) bool {
+	if isFinalError(err) {
+		return false
+	}
+	if p.Classify != nil {
+		return p.Classify(err)
+	}
+	return defaultRetryClassify(err, p.RetryStatuses)
+}
+
+// isFinalError 不论怎么分类都不应该重试的错误
+func isFinalError(err error) bool {
+	return err == nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrDisallowedByRobots) ||
+		errors.Is(err, ErrNoRecording)
+}
+
+// Backoff 返回第 attempt 次失败后需要等待的时间，attempt 从 1 开始
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	if p.RespectRetryAfter {
+		var se *StatusError
+		if errors.As(err, &se) {
+			if d, ok := parseRetryAfter(se.Header.Get("Retry-After")); ok {
+				// 防止服务器返回很大的 Retry-After 让 worker 长时间挂起
+				if p.MaxDelay > 0 && d > p.MaxDelay {
+					d = p.MaxDelay
+				}
+				return d
+			}
+		}
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	return d
+}
+
+// parseRetryAfter 解析 Retry-After，支持秒数和 http 时间两种格式
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
@@ -0,0 +1,70 @@
+package kcrawl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRawCrawlerRetry(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		switch r.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = 10 * time.Millisecond
+	c := NewRawCrawler(0, nil, RawWithRetry(policy))
+
+	data, err := c.Get(ts.URL + "/flaky")
+	if err != nil {
+		t.Fatalf("重试后应该成功: %v", err)
+	}
+	if string(data) != "ok" || hits.Load() != 3 {
+		t.Fatalf("期望请求 3 次得到 ok, 实际 %d 次 %q", hits.Load(), data)
+	}
+
+	hits.Store(0)
+	_, err = c.Get(ts.URL + "/missing")
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("期望 404 StatusError, 得到 %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("404 不应该重试, 实际请求 %d 次", hits.Load())
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.Backoff(i+1, errors.New("x")); got != w {
+			t.Fatalf("attempt %d: 期望 %v, 得到 %v", i+1, w, got)
+		}
+	}
+	p.RespectRetryAfter = true
+	err := &StatusError{StatusCode: 429, Header: http.Header{"Retry-After": {"3"}}}
+	if got := p.Backoff(1, err); got != 3*time.Second {
+		t.Fatalf("期望遵循 Retry-After 3s, 得到 %v", got)
+	}
+	err.Header.Set("Retry-After", "86400")
+	if got := p.Backoff(1, err); got != 5*time.Second {
+		t.Fatalf("Retry-After 不应该超过 MaxDelay, 得到 %v", got)
+	}
+}
@@ -0,0 +1,340 @@
+package kcrawl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevin-zx/kbase/kcache"
+)
+
+// ErrDisallowedByRobots 请求的 url 被 robots.txt 禁止，可以用 errors.Is 判断
+var ErrDisallowedByRobots = errors.New("kcrawl: disallowed by robots.txt")
+
+// RobotsPolicy 遵守 robots.txt 的策略，可以在多个 crawler 之间共用。
+// robots.txt 通过 kcache 缓存，Crawl-delay 会用来控制对同一个 host 的请求间隔
+type RobotsPolicy struct {
+	cache     kcache.KCache
+	userAgent string
+	// robots.txt 的缓存时间
+	ttl    time.Duration
+	client *http.Client
+
+	mu     sync.Mutex
+	rules  map[string]*robotsRules
+	nextAt map[string]time.Time
+}
+
+type RobotsOption func(p *RobotsPolicy)
+
+// RobotsWithTTL robots.txt 的缓存时间，默认 24 小时
+func RobotsWithTTL(ttl time.Duration) RobotsOption {
+	return func(p *RobotsPolicy) {
+		p.ttl = ttl
+	}
+}
+
+// RobotsWithHTTPClient 直接调用 Allowed、CrawlDelay 时获取 robots.txt 使用的 client，默认超时 30 秒。
+// 在 crawler 中使用时通过 crawler 自己的代理、cookie 和 Recorder 获取
+func RobotsWithHTTPClient(client *http.Client) RobotsOption {
+	return func(p *RobotsPolicy) {
+		p.client = client
+	}
+}
+
+// NewRobotsPolicy userAgent 用来匹配 robots.txt 中的 User-agent，也会作为获取 robots.txt 时的 User-Agent
+func NewRobotsPolicy(cache kcache.KCache, userAgent string, opts ...RobotsOption) *RobotsPolicy {
+	p := &RobotsPolicy{
+		cache:     cache,
+		userAgent: userAgent,
+		ttl:       24 * time.Hour,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		rules:     make(map[string]*robotsRules),
+		nextAt:    make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// robotsCacheData 缓存的 robots.txt
+type robotsCacheData struct {
+	StatusCode int       `json:"status_code"`
+	Body       string    `json:"body"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+// Allowed 判断 rawURL 是否允许抓取
+func (p *RobotsPolicy) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, err
+	}
+	rules, err := p.hostRules(ctx, u, p.client)
+	if err != nil {
+		return false, err
+	}
+	return rules.allowed(robotsPath(u)), nil
+}
+
+// CrawlDelay 返回 host 的 Crawl-delay，没有设置时为 0
+func (p *RobotsPolicy) CrawlDelay(ctx context.Context, rawURL string) (time.Duration, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	rules, err := p.hostRules(ctx, u, p.client)
+	if err != nil {
+		return 0, err
+	}
+	return rules.crawlDelay, nil
+}
+
+// check 不允许抓取时返回 ErrDisallowedByRobots，允许时按 Crawl-delay 等待。
+// client 为 crawler 发送请求的 client，robots.txt 和正常的请求走同样的代理和 Recorder
+func (p *RobotsPolicy) check(ctx context.Context, u *url.URL, client *http.Client) error {
+	rules, err := p.hostRules(ctx, u, client)
+	if err != nil {
+		return err
+	}
+	if !rules.allowed(robotsPath(u)) {
+		return fmt.Errorf("%w: %s", ErrDisallowedByRobots, u.String())
+	}
+	if rules.crawlDelay <= 0 {
+		return nil
+	}
+	// 预定下一次请求的时间，等待期间取消时不归还，宁可慢一点
+	p.mu.Lock()
+	now := time.Now()
+	at := p.nextAt[u.Host]
+	if at.Before(now) {
+		at = now
+	}
+	p.nextAt[u.Host] = at.Add(rules.crawlDelay)
+	p.mu.Unlock()
+	return sleepCtx(ctx, time.Until(at))
+}
+
+func robotsPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+func (p *RobotsPolicy) hostRules(ctx context.Context, u *url.URL, client *http.Client) (*robotsRules, error) {
+	origin := u.Scheme + "://" + u.Host
+	p.mu.Lock()
+	rules, ok := p.rules[origin]
+	p.mu.Unlock()
+	if ok && time.Since(rules.fetchedAt) < p.ttl {
+		return rules, nil
+	}
+
+	key := "robots.txt:" + origin
+	var cd robotsCacheData
+	data, err := p.cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || json.Unmarshal(data, &cd) != nil || time.Since(cd.FetchedAt) >= p.ttl {
+		cd, err = p.fetch(ctx, origin, client)
+		if err != nil {
+			return nil, err
+		}
+		data, _ = json.Marshal(&cd)
+		if err = p.cache.Save(key, data); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case cd.StatusCode >= 200 && cd.StatusCode < 300:
+		rules = parseRobots(strings.NewReader(cd.Body), p.userAgent)
+	default:
+		// 4xx 表示没有 robots.txt，全部允许
+		rules = &robotsRules{}
+	}
+	rules.fetchedAt = cd.FetchedAt
+	p.mu.Lock()
+	p.rules[origin] = rules
+	p.mu.Unlock()
+	return rules, nil
+}
+
+// fetch 获取 robots.txt，5xx 和网络错误直接返回错误，不缓存
+func (p *RobotsPolicy) fetch(ctx context.Context, origin string, client *http.Client) (robotsCacheData, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return robotsCacheData{}, err
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return robotsCacheData{}, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	defer res.Body.Close()
+	// 和 google 一样，只读取前 500KB
+	body, err := io.ReadAll(io.LimitReader(res.Body, 500<<10))
+	if err != nil {
+		return robotsCacheData{}, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	if res.StatusCode >= 500 {
+		return robotsCacheData{}, fmt.Errorf("fetch robots.txt: %w", &StatusError{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: body})
+	}
+	return robotsCacheData{StatusCode: res.StatusCode, Body: string(body), FetchedAt: time.Now()}, nil
+}
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	fetchedAt  time.Time
+}
+
+// allowed 最长匹配的规则生效，长度相同时 allow 优先
+func (r *robotsRules) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if len(rule.pattern) < best || !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || rule.allow {
+			allow = rule.allow
+		}
+		best = len(rule.pattern)
+	}
+	return allow
+}
+
+// robotsMatch 支持 * 和结尾的 $
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return true
+}
+
+type robotsGroup struct {
+	agents []string
+	robotsRules
+}
+
+// parseRobots 选出最匹配 userAgent 的组，没有时使用 * 组
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	var groups []*robotsGroup
+	var cur *robotsGroup
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+		switch field {
+		case "user-agent":
+			if !inAgents {
+				cur = &robotsGroup{}
+				groups = append(groups, cur)
+				inAgents = true
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			// 空的 Disallow 表示全部允许
+			if cur == nil || value == "" {
+				continue
+			}
+			cur.rules = append(cur.rules, robotsRule{pattern: value, allow: field == "allow"})
+		case "crawl-delay":
+			inAgents = false
+			if cur == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				cur.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		default:
+			// sitemap 等其它字段不影响分组
+		}
+	}
+
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	rules := &robotsRules{}
+	bestLen := -1
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			l := -1
+			if agent == "*" {
+				l = 0
+			} else if token != "" && strings.Contains(token, agent) {
+				l = len(agent)
+			}
+			if l < 0 || l < bestLen {
+				continue
+			}
+			if l > bestLen {
+				rules = &robotsRules{}
+				bestLen = l
+			}
+			// 同一个 user-agent 的多个组合并
+			rules.rules = append(rules.rules, g.rules...)
+			if g.crawlDelay > rules.crawlDelay {
+				rules.crawlDelay = g.crawlDelay
+			}
+		}
+	}
+	return rules
+}
@@ -0,0 +1,117 @@
+package kcrawl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	txt := `
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Crawl-delay: 1
+
+User-agent: kbot
+Disallow: /*.pdf$
+Disallow: /tmp/
+Allow: /tmp/ok
+Crawl-delay: 0.5
+`
+	cases := []struct {
+		ua    string
+		path  string
+		allow bool
+	}{
+		{"other", "/private/x", false},
+		{"other", "/private/public/x", true},
+		{"other", "/a.pdf", true},
+		{"kbot/1.0", "/private/x", true},
+		{"kbot/1.0", "/a.pdf", false},
+		{"kbot/1.0", "/a.pdf?x=1", true},
+		{"kbot/1.0", "/tmp/a", false},
+		{"kbot/1.0", "/tmp/ok", true},
+		{"kbot/1.0", "/robots.txt", true},
+	}
+	for _, c := range cases {
+		rules := parseRobots(strings.NewReader(txt), c.ua)
+		if got := rules.allowed(c.path); got != c.allow {
+			t.Fatalf("%s %s: 期望 %v, 得到 %v", c.ua, c.path, c.allow, got)
+		}
+	}
+	if d := parseRobots(strings.NewReader(txt), "kbot").crawlDelay; d != 500*time.Millisecond {
+		t.Fatalf("crawl-delay 期望 500ms, 得到 %v", d)
+	}
+}
+
+func TestRawCrawlerRobots(t *testing.T) {
+	var robotsHits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsHits.Add(1)
+			w.Write([]byte("User-agent: *\nDisallow: /admin\nCrawl-delay: 0.05\n"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	policy := NewRobotsPolicy(newTestCache(t), "kbot")
+	c := NewRawCrawler(0, nil, RawWithRobots(policy), RawWithRetry(DefaultRetryPolicy()))
+	_, err := c.Get(ts.URL + "/admin/x")
+	if !errors.Is(err, ErrDisallowedByRobots) {
+		t.Fatalf("期望 ErrDisallowedByRobots, 得到 %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err = c.Get(ts.URL + "/page"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("Crawl-delay 没有生效, 3 次请求只用了 %v", d)
+	}
+	if robotsHits.Load() != 1 {
+		t.Fatalf("robots.txt 应该只请求一次, 实际 %d 次", robotsHits.Load())
+	}
+}
+
+func TestRobotsThroughRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	path := filepath.Join(t.TempDir(), "rec.har")
+	rec, err := NewHARRecorder(path, RecordModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewRawCrawler(0, nil, RawWithRobots(NewRobotsPolicy(newTestCache(t), "kbot")), RawWithRecorder(rec))
+	if _, err = c.Get(ts.URL + "/page"); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	// 回放时 robots.txt 也从记录中读取，不访问网络
+	rec, err = NewHARRecorder(path, RecordModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewRawCrawler(0, nil, RawWithRobots(NewRobotsPolicy(newTestCache(t), "kbot")), RawWithRecorder(rec))
+	if _, err = c.Get(ts.URL + "/page"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get(ts.URL + "/admin"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Fatalf("期望 ErrDisallowedByRobots, 得到 %v", err)
+	}
+}
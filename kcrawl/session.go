@@ -0,0 +1,449 @@
+package kcrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kevin-zx/kbase/kcache"
+	"golang.org/x/net/publicsuffix"
+)
+
+// SessionStore 持久化 Session 的 cookie
+type SessionStore interface {
+	// Load 没有数据时返回 nil, nil
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+type fileSessionStore struct {
+	path string
+}
+
+// NewFileSessionStore 把 cookie 保存到文件
+func NewFileSessionStore(path string) SessionStore {
+	return &fileSessionStore{path: path}
+}
+
+func (s *fileSessionStore) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *fileSessionStore) Save(data []byte) error {
+	return os.WriteFile(s.path, data, 0600)
+}
+
+type kcacheSessionStore struct {
+	cache kcache.KCache
+	key   string
+}
+
+// NewKCacheSessionStore 把 cookie 保存到 cache 的 key 下
+func NewKCacheSessionStore(cache kcache.KCache, key string) SessionStore {
+	return &kcacheSessionStore{cache: cache, key: key}
+}
+
+func (s *kcacheSessionStore) Load() ([]byte, error) {
+	return s.cache.Get(s.key)
+}
+
+func (s *kcacheSessionStore) Save(data []byte) error {
+	return s.cache.Save(s.key, data)
+}
+
+// SessionCookie 持久化和导入导出用的 cookie 格式，和浏览器插件（EditThisCookie 等）导出的 json 兼容
+type SessionCookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+	// unix 秒，0 表示会话 cookie
+	ExpirationDate float64 `json:"expirationDate,omitempty"`
+	Secure         bool    `json:"secure,omitempty"`
+	HttpOnly       bool    `json:"httpOnly,omitempty"`
+	// 只发给 Domain 本身，不发给子域名
+	HostOnly bool `json:"hostOnly,omitempty"`
+}
+
+func (sc *SessionCookie) expired(now time.Time) bool {
+	return sc.ExpirationDate > 0 && now.Unix() >= int64(sc.ExpirationDate)
+}
+
+func (sc *SessionCookie) httpCookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     sc.Name,
+		Value:    sc.Value,
+		Path:     sc.Path,
+		Secure:   sc.Secure,
+		HttpOnly: sc.HttpOnly,
+	}
+	if !sc.HostOnly {
+		c.Domain = sc.Domain
+	}
+	if sc.ExpirationDate > 0 {
+		c.Expires = time.Unix(int64(sc.ExpirationDate), 0)
+	}
+	return c
+}
+
+func (sc *SessionCookie) url() *url.URL {
+	scheme := "http"
+	if sc.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: sc.Domain, Path: sc.Path}
+}
+
+// Session 带持久化的 cookie jar，实现了 http.CookieJar，可以在多个 crawler 之间共用。
+// 标准库的 cookiejar 不能遍历，所以这里额外记录一份服务器设置过的 cookie，用来持久化和查看
+type Session struct {
+	mu sync.Mutex
+	// Cookies 在每次请求时调用，不加锁读取；替换 jar 时需要持有 mu
+	jar   atomic.Pointer[cookiejar.Jar]
+	store SessionStore
+	// domain -> name + path -> cookie
+	cookies map[string]map[string]*SessionCookie
+}
+
+// NewSession 创建 Session，store 不为空时会先从 store 恢复 cookie，之后每次 cookie 变化都会保存
+func NewSession(store SessionStore) (*Session, error) {
+	s := &Session{
+		store:   store,
+		cookies: make(map[string]map[string]*SessionCookie),
+	}
+	if err := s.resetJar(); err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return s, nil
+	}
+	data, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+	var cookies []*SessionCookie
+	if err = json.Unmarshal(data, &cookies); err != nil {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(cookies)
+	return s, nil
+}
+
+func (s *Session) resetJar() error {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return err
+	}
+	s.jar.Store(jar)
+	return nil
+}
+
+// SetCookies 实现 http.CookieJar，只记录 jar 接受的 cookie，cookie 有变化时才保存到 store
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jar.Load().SetCookies(u, cookies)
+	now := time.Now()
+	host := strings.ToLower(u.Hostname())
+	changed := false
+	for _, c := range cookies {
+		domain, hostOnly, ok := cookieDomain(host, c.Domain)
+		if !ok {
+			// jar 也会拒绝，比如其它网站或者公共后缀的 Domain
+			continue
+		}
+		sc := &SessionCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			HostOnly: hostOnly,
+		}
+		if sc.Path == "" || !strings.HasPrefix(sc.Path, "/") {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.ExpirationDate = float64(now.Unix() - 1)
+		case c.MaxAge > 0:
+			sc.ExpirationDate = float64(now.Add(time.Duration(c.MaxAge) * time.Second).Unix())
+		case !c.Expires.IsZero():
+			sc.ExpirationDate = float64(c.Expires.Unix())
+		}
+		if s.record(sc, now) {
+			changed = true
+		}
+	}
+	if changed {
+		// 保存失败不影响请求，需要确认是否保存成功可以调用 Save
+		_ = s.save()
+	}
+}
+
+// cookieDomain 和 cookiejar 一样校验 Domain 属性：必须是 host 本身或者它的父域名，并且不能是公共后缀。
+// 返回去掉前导点的 domain，没有 Domain 属性时为 host 并且 hostOnly 为 true
+func cookieDomain(host string, domain string) (string, bool, bool) {
+	if domain == "" {
+		return host, true, true
+	}
+	if net.ParseIP(host) != nil {
+		return host, true, host == domain
+	}
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", false, false
+	}
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		// cookiejar 把 host 本身是公共后缀的当作 host-only cookie
+		return host, true, host == domain
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// Cookies 实现 http.CookieJar
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	return s.jar.Load().Cookies(u)
+}
+
+// defaultCookiePath 参考 RFC 6265 5.1.4
+func defaultCookiePath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// expiryTolerance 过期时间的变化小于这个值时不当作 cookie 变化，避免每次响应都带 Max-Age 时频繁保存
+const expiryTolerance = time.Minute
+
+// record 记录 cookie，返回是否有变化
+func (s *Session) record(sc *SessionCookie, now time.Time) bool {
+	key := sc.Name + "\x00" + sc.Path
+	old, exists := s.cookies[sc.Domain][key]
+	if sc.expired(now) {
+		if !exists {
+			return false
+		}
+		delete(s.cookies[sc.Domain], key)
+		if len(s.cookies[sc.Domain]) == 0 {
+			delete(s.cookies, sc.Domain)
+		}
+		return true
+	}
+	if s.cookies[sc.Domain] == nil {
+		s.cookies[sc.Domain] = make(map[string]*SessionCookie)
+	}
+	s.cookies[sc.Domain][key] = sc
+	if !exists {
+		return true
+	}
+	same := *old
+	same.ExpirationDate = sc.ExpirationDate
+	diff := time.Duration(sc.ExpirationDate-old.ExpirationDate) * time.Second
+	if (old.ExpirationDate == 0) != (sc.ExpirationDate == 0) {
+		return true
+	}
+	return same != *sc || diff >= expiryTolerance || diff <= -expiryTolerance
+}
+
+// add 把 cookie 写入 jar 和记录，调用方需要持有锁
+func (s *Session) add(cookies []*SessionCookie) {
+	now := time.Now()
+	for _, sc := range cookies {
+		sc.Domain = strings.TrimPrefix(strings.ToLower(sc.Domain), ".")
+		if sc.Path == "" {
+			sc.Path = "/"
+		}
+		if sc.Domain == "" || sc.expired(now) {
+			continue
+		}
+		s.jar.Load().SetCookies(sc.url(), []*http.Cookie{sc.httpCookie()})
+		s.record(sc, now)
+	}
+}
+
+// all 返回所有 cookie，按 domain、name 排序，调用方需要持有锁
+func (s *Session) all() []*SessionCookie {
+	now := time.Now()
+	var all []*SessionCookie
+	for _, m := range s.cookies {
+		for _, sc := range m {
+			if !sc.expired(now) {
+				all = append(all, sc)
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Domain != all[j].Domain {
+			return all[i].Domain < all[j].Domain
+		}
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].Path < all[j].Path
+	})
+	return all
+}
+
+func (s *Session) save() error {
+	if s.store == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.all())
+	if err != nil {
+		return err
+	}
+	return s.store.Save(data)
+}
+
+// Save 手动保存到 store
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// Domains 返回有 cookie 的 domain
+func (s *Session) Domains() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	domains := make([]string, 0, len(s.cookies))
+	for d := range s.cookies {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// DomainCookies 返回 domain 下设置的 cookie，不包含父域名的 cookie
+func (s *Session) DomainCookies(domain string) []*SessionCookie {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cookies []*SessionCookie
+	for _, sc := range s.all() {
+		if sc.Domain == domain {
+			c := *sc
+			cookies = append(cookies, &c)
+		}
+	}
+	return cookies
+}
+
+// ClearDomain 删除 domain 下的所有 cookie
+func (s *Session) ClearDomain(domain string) error {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cookies, domain)
+	return s.rebuild()
+}
+
+// Clear 删除所有 cookie
+func (s *Session) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cookies = make(map[string]map[string]*SessionCookie)
+	return s.rebuild()
+}
+
+// rebuild cookiejar 不支持删除，只能用剩下的 cookie 重建一个
+func (s *Session) rebuild() error {
+	remain := s.all()
+	if err := s.resetJar(); err != nil {
+		return err
+	}
+	s.cookies = make(map[string]map[string]*SessionCookie)
+	s.add(remain)
+	return s.save()
+}
+
+// ImportJSON 导入 json 数组格式的 cookie，格式见 SessionCookie
+func (s *Session) ImportJSON(r io.Reader) error {
+	var cookies []*SessionCookie
+	if err := json.NewDecoder(r).Decode(&cookies); err != nil {
+		return fmt.Errorf("import json cookies: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(cookies)
+	return s.save()
+}
+
+// ImportNetscape 导入 Netscape cookies.txt 格式的 cookie（curl、wget 和大部分浏览器插件都支持导出）
+func (s *Session) ImportNetscape(r io.Reader) error {
+	var cookies []*SessionCookie
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		// 值为空的 cookie 以 tab 结尾，只去掉行尾的 \r，不能 TrimSpace
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("import netscape cookies: line %d: expect 7 fields, got %d", lineNum, len(fields))
+		}
+		expires, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return fmt.Errorf("import netscape cookies: line %d: %w", lineNum, err)
+		}
+		cookies = append(cookies, &SessionCookie{
+			Domain:         fields[0],
+			HostOnly:       !strings.EqualFold(fields[1], "TRUE"),
+			Path:           fields[2],
+			Secure:         strings.EqualFold(fields[3], "TRUE"),
+			ExpirationDate: expires,
+			Name:           fields[5],
+			Value:          fields[6],
+			HttpOnly:       httpOnly,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(cookies)
+	return s.save()
+}
@@ -0,0 +1,163 @@
+package kcrawl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSessionPersist(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/"})
+			return
+		}
+		c, err := r.Cookie("sid")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(c.Value))
+	}))
+	defer ts.Close()
+
+	store := NewFileSessionStore(filepath.Join(t.TempDir(), "session.json"))
+	s, err := NewSession(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewRawCrawler(0, nil, RawWithSession(s)).Get(ts.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启，从 store 恢复
+	s2, err := NewSession(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := NewRawCrawler(0, nil, RawWithSession(s2)).Get(ts.URL + "/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abc" {
+		t.Fatalf("期望恢复 sid=abc, 得到 %q", data)
+	}
+
+	host := strings.Split(strings.TrimPrefix(ts.URL, "http://"), ":")[0]
+	if cs := s2.DomainCookies(host); len(cs) != 1 || cs[0].Name != "sid" {
+		t.Fatalf("DomainCookies 不对: %+v", cs)
+	}
+	if err = s2.ClearDomain(host); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewRawCrawler(0, nil, RawWithSession(s2)).Get(ts.URL + "/me"); err == nil {
+		t.Fatal("清除 cookie 之后应该返回 401")
+	}
+}
+
+func TestSessionImportNetscape(t *testing.T) {
+	s, err := NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\ta\t1\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/\tTRUE\t4102444800\tb\t2\n" +
+		"example.com\tTRUE\t/\tFALSE\t1\texpired\t3\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tempty\t\r\n"
+	if err = s.ImportNetscape(strings.NewReader(txt)); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://www.example.com/")
+	got := map[string]string{}
+	for _, c := range s.Cookies(u) {
+		got[c.Name] = c.Value
+	}
+	if v, ok := got["empty"]; len(got) != 3 || got["a"] != "1" || got["b"] != "2" || !ok || v != "" {
+		t.Fatalf("导入的 cookie 不对: %v", got)
+	}
+	if cs := s.DomainCookies("www.example.com"); len(cs) != 1 || !cs[0].HttpOnly || !cs[0].HostOnly {
+		t.Fatalf("DomainCookies 不对: %+v", cs)
+	}
+}
+
+type countingStore struct {
+	saves atomic.Int32
+	data  atomic.Value
+}
+
+func (s *countingStore) Load() ([]byte, error) {
+	data, _ := s.data.Load().([]byte)
+	return data, nil
+}
+
+func (s *countingStore) Save(data []byte) error {
+	s.saves.Add(1)
+	s.data.Store(data)
+	return nil
+}
+
+func TestSessionRejectsForeignDomain(t *testing.T) {
+	store := &countingStore{}
+	s, err := NewSession(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evil, _ := url.Parse("https://evil.com/")
+	s.SetCookies(evil, []*http.Cookie{
+		{Name: "a", Value: "1", Domain: "bank.com"},
+		{Name: "b", Value: "2", Domain: ".com"},
+	})
+	if d := s.Domains(); len(d) != 0 || store.saves.Load() != 0 {
+		t.Fatalf("jar 拒绝的 cookie 不应该被记录: %v, 保存了 %d 次", d, store.saves.Load())
+	}
+	www, _ := url.Parse("https://www.example.com/a/b")
+	s.SetCookies(www, []*http.Cookie{{Name: "c", Value: "3", Domain: "example.com"}, {Name: "d", Value: "4"}})
+	s.SetCookies(www, []*http.Cookie{{Name: "c", Value: "3", Domain: "example.com"}, {Name: "d", Value: "4"}})
+	if d := s.Domains(); len(d) != 2 || d[0] != "example.com" || d[1] != "www.example.com" {
+		t.Fatalf("domain 不对: %v", d)
+	}
+	if store.saves.Load() != 1 {
+		t.Fatalf("cookie 没有变化时不应该保存, 保存了 %d 次", store.saves.Load())
+	}
+
+	// 重启之后也不会出现 bank.com 的 cookie
+	s2, err := NewSession(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank, _ := url.Parse("https://bank.com/")
+	if cs := s2.Cookies(bank); len(cs) != 0 {
+		t.Fatalf("不应该有 bank.com 的 cookie: %v", cs)
+	}
+}
+
+func TestSessionConcurrentClear(t *testing.T) {
+	s, err := NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://example.com/")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				s.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1"}})
+				s.Cookies(u)
+			}
+		}()
+	}
+	for k := 0; k < 20; k++ {
+		if err = s.Clear(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,133 @@
+package kcrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Validator 校验响应，返回错误时这次请求失败，不会缓存。
+// 常用于识别状态码是 200 的验证码页、登录页
+type Validator func(res *Response) error
+
+// ValidationError 响应没有通过校验，默认的重试分类会重试
+type ValidationError struct {
+	URL string
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validate %s: %s", e.URL, e.Err.Error())
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func validate(res *Response, validators []Validator) error {
+	for _, v := range validators {
+		if err := v(res); err != nil {
+			return &ValidationError{URL: res.URL, Err: err}
+		}
+	}
+	return nil
+}
+
+// ValidJSON body 必须是合法的 json
+func ValidJSON() Validator {
+	return func(res *Response) error {
+		if !json.Valid(res.Body) {
+			return errors.New("body is not valid json")
+		}
+		return nil
+	}
+}
+
+// MatchRegexp body 必须匹配 re
+func MatchRegexp(re *regexp.Regexp) Validator {
+	return func(res *Response) error {
+		if !re.Match(res.Body) {
+			return fmt.Errorf("body does not match %s", re.String())
+		}
+		return nil
+	}
+}
+
+// NotContains body 不能包含任意一个 marker，比如 "验证码"、"请登录"
+func NotContains(markers ...string) Validator {
+	return func(res *Response) error {
+		for _, m := range markers {
+			if bytes.Contains(res.Body, []byte(m)) {
+				return fmt.Errorf("body contains block marker %q", m)
+			}
+		}
+		return nil
+	}
+}
+
+// MatchJSONPath body 是 json，并且 path 指向的值存在；re 不为空时值还必须匹配 re。
+// path 支持 $.data.items[0].id 和 data.items.0.id 两种写法，字符串按原值匹配，其它类型按 json 文本匹配
+func MatchJSONPath(path string, re *regexp.Regexp) Validator {
+	keys := parseJSONPath(path)
+	return func(res *Response) error {
+		var v any
+		if err := json.Unmarshal(res.Body, &v); err != nil {
+			return fmt.Errorf("body is not valid json: %w", err)
+		}
+		v, ok := lookupJSONPath(v, keys)
+		if !ok {
+			return fmt.Errorf("json path %s not found", path)
+		}
+		if re == nil {
+			return nil
+		}
+		s, isString := v.(string)
+		if !isString {
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		if !re.MatchString(s) {
+			return fmt.Errorf("json path %s value %q does not match %s", path, s, re.String())
+		}
+		return nil
+	}
+}
+
+func parseJSONPath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	var keys []string
+	for _, k := range strings.Split(path, ".") {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func lookupJSONPath(v any, keys []string) (any, bool) {
+	for _, k := range keys {
+		switch t := v.(type) {
+		case map[string]any:
+			var ok bool
+			v, ok = t[k]
+			if !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
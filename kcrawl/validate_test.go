@@ -0,0 +1,70 @@
+package kcrawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidators(t *testing.T) {
+	res := &Response{Body: []byte(`{"code":0,"data":{"items":[{"id":"a1"}]}}`)}
+	cases := []struct {
+		v  Validator
+		ok bool
+	}{
+		{ValidJSON(), true},
+		{MatchJSONPath("$.data.items[0].id", regexp.MustCompile(`^a\d$`)), true},
+		{MatchJSONPath("data.items.0.id", nil), true},
+		{MatchJSONPath("code", regexp.MustCompile(`^0$`)), true},
+		{MatchJSONPath("$.data.items[1]", nil), false},
+		{MatchJSONPath("code", regexp.MustCompile(`^1$`)), false},
+		{MatchRegexp(regexp.MustCompile(`"items"`)), true},
+		{NotContains("验证码"), true},
+		{NotContains("items"), false},
+	}
+	for i, c := range cases {
+		if err := c.v(res); (err == nil) != c.ok {
+			t.Fatalf("case %d: 期望 %v, 得到 %v", i, c.ok, err)
+		}
+	}
+	if ValidJSON()(&Response{Body: []byte("<html>")}) == nil {
+		t.Fatal("html 不应该是合法的 json")
+	}
+}
+
+func TestRawCacheCrawlerValidators(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Write([]byte("<html>请输入验证码</html>"))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+
+	retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	c := NewRawCacheCrawler(newTestCache(t), RawCacheWithValidators(ValidJSON(), NotContains("验证码")), RawCacheWithRetry(retry))
+	body, err := c.Get(ts.URL)
+	if err != nil || string(body) != `{"ok":true}` || hits.Load() != 2 {
+		t.Fatalf("应该重试后成功: %s %v, 请求 %d 次", body, err, hits.Load())
+	}
+
+	// 没有重试时直接返回 ValidationError，并且不缓存
+	hits.Store(0)
+	c = NewRawCacheCrawler(newTestCache(t), RawCacheWithValidators(NotContains("验证码")))
+	_, err = c.DoResponse(context.Background(), &Request{URL: ts.URL})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("期望 ValidationError, 得到 %v", err)
+	}
+	body, err = c.Get(ts.URL)
+	if err != nil || string(body) != `{"ok":true}` || hits.Load() != 2 {
+		t.Fatalf("校验失败的响应不应该缓存: %s %v, 请求 %d 次", body, err, hits.Load())
+	}
+}
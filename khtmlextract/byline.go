@@ -0,0 +1,168 @@
+package khtmlextract
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+	"golang.org/x/net/html"
+)
+
+// Confidence 各个字段的置信度，取值 0~1，0 表示没有找到
+type Confidence struct {
+	PublishedAt float64
+	Author      float64
+	Source      float64
+}
+
+// byline 发布时间、作者和来源
+type byline struct {
+	publishedAt time.Time
+	author      string
+	source      string
+	confidence  Confidence
+}
+
+// 不同来源的置信度，结构化数据最高，正文中的文字最低
+const (
+	confMeta      = 0.9
+	confJSONLD    = 0.9
+	confTimeTag   = 0.7
+	confItemprop  = 0.7
+	confLabeled   = 0.8
+	confBare      = 0.5
+	confSiteName  = 0.4
+	farFromTitle  = 0.2
+	titleWindow   = 600
+	noTitleWindow = 1000
+)
+
+// defaultLocation 没有设置 ExtractOptions.Location 时使用的时区
+var defaultLocation = htmlutil.DefaultLocation()
+
+var (
+	// labeledDateRe 如 发布时间：2024-01-02 10:00
+	labeledDateRe = regexp.MustCompile(`(?:发布|发表|更新|出版)?(?:时间|日期)\s*[:：]?\s*` + htmlutil.DateRe)
+	authorRe      = regexp.MustCompile(`(?:作者|记者|撰文)\s*[:：]\s*([^\s　|｜/，,。：:]{1,20})`)
+	sourceRe      = regexp.MustCompile(`(?:来源|出处)\s*[:：]\s*([^\s　|｜/，,。：:]{1,30})`)
+)
+
+// detectByline 按 meta、JSON-LD、<time> 和标题附近的文字依次查找，取置信度最高的结果。
+// meta 和 JSON-LD 使用 extractPageMeta 已经解析好的结果
+func detectByline(doc *goquery.Document, m *PageMeta, loc *time.Location) byline {
+	var b byline
+	metas, jsonLD := m.metas, m.JSONLD
+
+	setTime := func(s string, conf float64) {
+		if conf <= b.confidence.PublishedAt {
+			return
+		}
+		if t, ok := htmlutil.ParseTime(s, loc); ok {
+			b.publishedAt = t
+			b.confidence.PublishedAt = conf
+		}
+	}
+	setAuthor := func(s string, conf float64) {
+		if s = strings.TrimSpace(s); s != "" && conf > b.confidence.Author {
+			b.author = s
+			b.confidence.Author = conf
+		}
+	}
+	setSource := func(s string, conf float64) {
+		if s = strings.TrimSpace(s); s != "" && conf > b.confidence.Source {
+			b.source = s
+			b.confidence.Source = conf
+		}
+	}
+
+	for _, k := range publishedTimeMetas {
+		setTime(metas[k], confMeta)
+	}
+	setTime(jsonLDString(jsonLD, "datePublished"), confJSONLD)
+	setTime(doc.Find("time[datetime]").First().AttrOr("datetime", ""), confTimeTag)
+
+	for _, k := range []string{"author", "article:author"} {
+		setAuthor(metas[k], confMeta)
+	}
+	setAuthor(jsonLDString(jsonLD, "author"), confJSONLD)
+	setAuthor(getClearTxt(doc.Find(`[itemprop="author"]`).First()), confItemprop)
+
+	for _, k := range []string{"source", "og:article:source", "article:source"} {
+		setSource(metas[k], confMeta)
+	}
+	setSource(jsonLDString(jsonLD, "publisher"), confJSONLD*0.8)
+	setSource(metas["og:site_name"], confSiteName)
+
+	text, near := textNearTitle(doc, getClearTxt(doc.Find("h1").First()))
+	penalty := 0.0
+	if !near {
+		penalty = farFromTitle
+	}
+	if m := labeledDateRe.FindStringSubmatch(text); m != nil {
+		if t, ok := htmlutil.DateFromMatch(m[1:], loc, ""); ok && confLabeled-penalty > b.confidence.PublishedAt {
+			b.publishedAt, b.confidence.PublishedAt = t, confLabeled-penalty
+		}
+	}
+	if m := htmlutil.BareDateRe.FindStringSubmatch(text); m != nil {
+		if t, ok := htmlutil.DateFromMatch(m[1:], loc, ""); ok && confBare-penalty > b.confidence.PublishedAt {
+			b.publishedAt, b.confidence.PublishedAt = t, confBare-penalty
+		}
+	}
+	if m := authorRe.FindStringSubmatch(text); m != nil {
+		setAuthor(m[1], confLabeled-penalty)
+	}
+	if m := sourceRe.FindStringSubmatch(text); m != nil {
+		setSource(m[1], confLabeled-penalty)
+	}
+	return b
+}
+
+// blockElements 前后需要换行的元素，避免相邻结点的文字连在一起
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "td": true, "section": true, "article": true,
+	"header": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// blockText 和 Selection.Text 一样，但是块级元素之间用换行分隔，并且跳过 script 和 style
+func blockText(n *html.Node, sb *strings.Builder) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if n.Data == "script" || n.Data == "style" {
+			return
+		}
+	}
+	block := n.Type == html.ElementNode && blockElements[n.Data]
+	if block {
+		sb.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		blockText(c, sb)
+	}
+	if block {
+		sb.WriteByte('\n')
+	}
+}
+
+// textNearTitle 返回正文中标题之后的一段文字，找不到标题时返回开头的一段，near 为 false
+func textNearTitle(doc *goquery.Document, title string) (text string, near bool) {
+	var sb strings.Builder
+	for _, n := range doc.Find("body").Nodes {
+		blockText(n, &sb)
+	}
+	text = sb.String()
+	window := noTitleWindow
+	start := 0
+	if title = strings.TrimSpace(title); title != "" {
+		if i := strings.Index(text, title); i >= 0 {
+			start, window, near = i+len(title), titleWindow, true
+		}
+	}
+	end := min(start+window, len(text))
+	// 截断的位置可能在一个字符中间
+	return strings.ToValidUTF8(text[start:end], ""), near
+}
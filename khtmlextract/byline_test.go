@@ -0,0 +1,73 @@
+package khtmlextract
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDetectByline(t *testing.T) {
+	doc := func(html string) *goquery.Document {
+		d, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	loc := time.FixedZone("CST", 8*3600)
+	detect := func(html string) byline {
+		d := doc(html)
+		m, err := extractPageMeta(d, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return detectByline(d, m, loc)
+	}
+
+	b := detect(`<html><body><div>导航 2020-01-01</div><h1>标题</h1>
+<div class="info">发布时间：2024-01-02 10:00 来源：新华社 作者：张三</div><p>正文</p></body></html>`)
+	if !b.publishedAt.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, loc)) || b.author != "张三" || b.source != "新华社" {
+		t.Fatalf("文字中的信息不对: %+v", b)
+	}
+	if b.confidence.PublishedAt != confLabeled || b.confidence.Source != confLabeled {
+		t.Fatalf("置信度不对: %+v", b.confidence)
+	}
+
+	b = detect(`<html><head>
+<meta property="article:published_time" content="2024-03-04T05:06:07+08:00">
+<meta name="author" content="李四">
+<meta property="og:site_name" content="示例网">
+</head><body><h1>标题</h1><p>发布时间：2020-01-01 作者：王五</p></body></html>`)
+	if !b.publishedAt.Equal(time.Date(2024, 3, 4, 5, 6, 7, 0, loc)) || b.author != "李四" || b.source != "示例网" {
+		t.Fatalf("meta 应该优先: %+v", b)
+	}
+	if b.confidence.PublishedAt != confMeta || b.confidence.Source != confSiteName {
+		t.Fatalf("置信度不对: %+v", b.confidence)
+	}
+
+	b = detect(`<html><body><p>没有任何信息</p></body></html>`)
+	if !b.publishedAt.IsZero() || b.confidence != (Confidence{}) {
+		t.Fatalf("没有信息时应该为空: %+v", b)
+	}
+}
+
+func TestExtractArticleByline(t *testing.T) {
+	a, err := ExtractArticle(optionsTestHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Source != "示例网" || !a.PublishedAt.IsZero() || a.Confidence.Source != confSiteName {
+		t.Fatalf("来源或者发布时间不对: %+v", a)
+	}
+
+	html := strings.Replace(optionsTestHTML, "<p>中国人民银行", "<p>2024年01月02日 10:00 来源：新华社</p><p>中国人民银行", 1)
+	a, err = ExtractArticle(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Source != "新华社" || a.PublishedAt.Location() != defaultLocation || a.PublishedAt.Hour() != 10 {
+		t.Fatalf("默认时区应该是 Asia/Shanghai: %+v", a)
+	}
+}
@@ -3,12 +3,12 @@ package khtmlextract
 
 import (
 	"fmt"
-	"math"
 	"strings"
-	"unicode"
+	"time"
 	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
 )
 
 // NodeInfo 存储每个结点的各种信息
@@ -33,64 +33,94 @@ type Article struct {
 	ContentHTML string
 	Score       float64
 	TextLength  int
+	// 从正文结点转换的 markdown，设置了 ExtractOptions.PageURL 时链接和图片是绝对地址
+	ContentMarkdown string
+	// 正文中的图片
+	Images []Image
+	// 发布时间，没有找到时为零值
+	PublishedAt time.Time
+	Author      string
+	// 来源，如 "来源：新华社" 中的新华社
+	Source     string
+	Confidence Confidence
+	// 页面的链接和元信息，和正文使用同一次解析的结果
+	Meta *PageMeta
+	// 正文结点，分页文章每页一个，DownloadImages 直接修改它们之后重新生成 ContentHTML
+	content []*html.Node
 }
 
 // ExtractArticle 从 html 中提取文章信息
 func ExtractArticle(html string) (*Article, error) {
-	infoMap, err := calculate(html)
+	return ExtractArticleWithOptions(html, ExtractOptions{})
+}
+
+// ExtractArticleWithOptions 按 opts 从 html 中提取文章信息
+func ExtractArticleWithOptions(html string, opts ExtractOptions) (*Article, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		return nil, err
 	}
+	// 元信息、发布时间等需要 script 中的 JSON-LD 和噪音结点中的文字，在计算得分删除它们之前提取
+	meta, err := extractPageMeta(doc, opts.PageURL)
+	if err != nil {
+		return nil, err
+	}
+	b := detectByline(doc, meta, opts.location())
+	title, summary := meta.Title, meta.Description
+	if opts.Title != TitleDefault {
+		title = extractTitle(doc, opts.Title)
+	}
+	nodes := scoreDoc(doc, opts.NoiseSelectors)
 	maxScore := 0.0
 	avgScore := 0.0
 	sumScore := 0.0
 	var maxNode *goquery.Selection
-	//if len(infoMap) <= 5 {
-	//	return nil, fmt.Errorf("can't find page content")
-	//}
-	//var maxInfo *NodeInfo
-	for node, info := range infoMap {
-		//h, _ := node.Html()
-		//fmt.Println("len:", len(strings.Split(node.Text(), "")), info.Score, node.Text(), h)
-		//fmt.Println("--------------------------------------------------------------------")
-		if info.Score > maxScore {
-			maxScore = info.Score
-			maxNode = node
-			//maxInfo = info
-
+	// 得分相同时使用先序遍历中的第一个结点
+	for _, n := range nodes {
+		if n.info.Score > maxScore {
+			maxScore = n.info.Score
+			maxNode = doc.FindNodes(n.node)
 		}
-		sumScore += info.Score
+		sumScore += n.info.Score
 	}
-	if len(infoMap) == 0 {
+	if len(nodes) == 0 {
 		return nil, fmt.Errorf("can't find page content")
 	}
-	avgScore = sumScore / float64(len(infoMap))
+	avgScore = sumScore / float64(len(nodes))
 	if maxNode == nil {
 		return nil, fmt.Errorf("can't find page content")
 	}
-	maxNode = removeSuccessiveLink(maxNode)
+	maxNode = removeSuccessiveLink(maxNode, opts.boilerplatePhrases())
 	if maxNode == nil {
 		return nil, fmt.Errorf("extract article err, can't get content node")
 	}
-	a := Article{}
+	a := Article{Title: title, Summary: summary, Meta: meta}
 	a.ContentHTML, err = goquery.OuterHtml(maxNode)
 	if err != nil {
 		return nil, err
 	}
 	a.ContentText = strings.ReplaceAll(getClearTxt(maxNode), "\n\n", "\n")
-	a.Title, a.Summary, err = getArticleInfo(html)
-	if err != nil {
-		return nil, err
-	}
+	a.ContentMarkdown = toMarkdown(maxNode, meta.base)
+	a.Images = collectImages(maxNode, meta.base)
+	node := maxNode.Get(0)
+	// 从页面中摘下正文结点，Article 不再引用整个页面
+	if node.Parent != nil {
+		node.Parent.RemoveChild(node)
+	}
+	a.content = append(a.content, node)
+	a.PublishedAt, a.Author, a.Source, a.Confidence = b.publishedAt, b.author, b.source, b.confidence
 	a.Score = maxScore / avgScore
 	a.TextLength = utf8.RuneCountInString(a.ContentText)
+	if opts.MinTextLength > 0 && a.TextLength < opts.MinTextLength {
+		return nil, fmt.Errorf("article text too short: %d < %d", a.TextLength, opts.MinTextLength)
+	}
 	return &a, err
 }
 
 // 有些网站中会把作者，来源等加上链接放入到文章中，还有就是一个div下面的第一级子节点就分布着标题，内容，上一篇下一篇，推荐等。
 // 这些链接主要特点是有连续性，然后，goquery dom子节点的遍历也是自上而下的，基于以上
 // 所以这个函数做清除用
-func removeSuccessiveLink(node *goquery.Selection) *goquery.Selection {
+func removeSuccessiveLink(node *goquery.Selection, phrases []string) *goquery.Selection {
 	var successionAs []*goquery.Selection
 	var needRemoveElements []*goquery.Selection
 	lastIndex := 0
@@ -98,7 +128,7 @@ func removeSuccessiveLink(node *goquery.Selection) *goquery.Selection {
 	node.Children().Each(func(i int, subNode *goquery.Selection) {
 		subNodeTxt := getClearTxt(subNode)
 		// 有些特殊特征的元素可以被删除
-		if len(strings.Split(subNodeTxt, "")) < 64 && (strings.HasPrefix(strings.TrimSpace(subNodeTxt), "上一篇") || strings.HasPrefix(strings.TrimSpace(subNodeTxt), "下一篇")) {
+		if utf8.RuneCountInString(subNodeTxt) < 64 && hasAnyPrefix(strings.TrimSpace(subNodeTxt), phrases) {
 			needRemoveElements = append(needRemoveElements, subNode)
 			lastIndex = i
 			return
@@ -145,17 +175,18 @@ func removeSuccessiveLink(node *goquery.Selection) *goquery.Selection {
 	return node
 }
 
-// getArticleInfo 解析 html 返回 title summary 信息
-func getArticleInfo(html string) (title string, summary string, err error) {
-	//
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
-	if err != nil {
-		return "", "", err
-	}
-	title = getClearTxt(doc.Find("H1"))
-	if title == "" {
-		title = doc.Find("title").Text()
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
 	}
+	return false
+}
+
+// articleInfo 从已经解析的 doc 中获取 title summary，ExtractArticle 和 ExtractPageMeta 共用
+func articleInfo(doc *goquery.Document, strategy TitleStrategy) (title string, summary string) {
+	title = extractTitle(doc, strategy)
 	doc.Find("meta").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
 		if strings.Contains(meta.AttrOr("name", ""), "description") || strings.Contains(meta.AttrOr("property", ""), "description") {
 			summary = meta.AttrOr("content", "")
@@ -166,105 +197,18 @@ func getArticleInfo(html string) (title string, summary string, err error) {
 	return
 }
 
-// compute node info
-func computeInfo(node *goquery.Selection, nodeMap map[*goquery.Selection]*NodeInfo) *NodeInfo {
-	var nodeInfo = &NodeInfo{}
-	if node.Children().Length() > 0 {
-		if node.Is("p") {
-			nodeInfo.PNum++
-		} else {
-
-			// 有子节点的情况下
-			node.Children().Each(func(_ int, child *goquery.Selection) {
-				// 计算当前结点的子节点的各种基础信息
-				// calculate all child node base info
-				childNodeInfo := computeInfo(child, nodeMap)
-				//nodeInfo.T += childNodeInfo.T
-				nodeInfo.LT += childNodeInfo.LT
-				nodeInfo.TG += childNodeInfo.TG
-				nodeInfo.LTG += childNodeInfo.LTG
-				nodeInfo.PNum += childNodeInfo.PNum
-				//nodeInfo.Sb += childNodeInfo.Sb
-			})
-
-		}
-		nodeInfo.T = len(getClearTxt(node))
-		if nodeInfo.T > 0 {
-			for _, r := range getClearTxt(node) {
-				if unicode.IsPunct(r) {
-					nodeInfo.Sb++
-				}
-			}
-		}
-		nodeInfo.TG++
-		nodeMap[node] = nodeInfo
-	} else {
-		// 没有子节点的情况下
-		// 累加指定结点 (a tag, p tag) 的信息
-		// accumulate special tags params
-		if node.Is("a") {
-			nodeInfo.LTG++
-			nodeInfo.LT = len(getClearTxt(node))
-		} else if node.Is("p") {
-			nodeInfo.PNum++
-		}
-		nodeInfo.TG = 1
-
-	}
-
-	return nodeInfo
-}
-
-func calculate(html string) (map[*goquery.Selection]*NodeInfo, error) {
-	nodeMap := make(map[*goquery.Selection]*NodeInfo)
-	doc, err := removeScriptAndStyle(html)
-	if err != nil {
-		return nil, err
-	}
-	body := doc.Find("body")
-	computeInfo(body, nodeMap)
-	sum := 0.0
-
-	for _, info := range nodeMap {
-		// 计算文本密度
-		// calculate text density
-		td := (float64(info.T-info.LT) / float64(info.TG-info.LTG+1)) * math.Log10(float64(info.TG-info.LTG+1))
-		info.TD = td
-		sum += td
-		info.SbD = float64(info.T-info.LT) / float64(info.Sb+1)
-	}
-	nodeInfoCount := float64(len(nodeMap))
-	avg := sum / nodeInfoCount
-
-	// 计算文本密度标准差
-	// calculate text density's standard deviation
-	sdp := 0.0
-	for _, info := range nodeMap {
-		sdp += math.Pow(info.TD-avg, 2) / (nodeInfoCount)
-
-	}
-	sd := math.Sqrt(sdp)
-
-	sdLog := math.Log(sd)
-	if sd == 0 {
-		sdLog = 0
-	}
-	for _, info := range nodeMap {
-		// 计算结点信息
-		// calculate node info score
-		// latex formula: score = \log_{}{SD}*ND_i*\log_{10}{(PNum_i)}*\log_{}{SbD_i}
-		info.Score = sdLog * info.TD * math.Log(float64(info.PNum+1)) * math.Log(info.SbD+1)
-	}
-
-	return nodeMap, nil
-}
-
-func removeScriptAndStyle(html string) (*goquery.Document, error) {
+// removeScriptAndStyle 解析 html 并删除 script、style 和 noiseSelectors 选中的结点
+func removeScriptAndStyle(html string, noiseSelectors ...string) (*goquery.Document, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		return nil, err
 	}
+	removeNoise(doc, noiseSelectors...)
+	return doc, nil
+}
 
+// removeNoise 删除 doc 中的 script、style 和 noiseSelectors 选中的结点
+func removeNoise(doc *goquery.Document, noiseSelectors ...string) {
 	doc.Find("script").Each(func(_ int, s *goquery.Selection) {
 		s.Remove()
 	})
@@ -272,10 +216,16 @@ func removeScriptAndStyle(html string) (*goquery.Document, error) {
 	doc.Find("style").Each(func(_ int, s *goquery.Selection) {
 		s.Remove()
 	})
-	return doc, nil
+	for _, selector := range noiseSelectors {
+		doc.Find(selector).Remove()
+	}
 }
 func getClearTxt(selection *goquery.Selection) string {
-	content := selection.Text()
+	return clearTxt(selection.Text())
+}
+
+// clearTxt 合并多余的空白和空行
+func clearTxt(content string) string {
 	for strings.Contains(content, " \n") {
 		content = strings.ReplaceAll(content, " \n", "\n")
 	}
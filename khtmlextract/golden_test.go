@@ -0,0 +1,139 @@
+package khtmlextract
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -run TestExtractArticleGolden -update 重新生成 testdata 中的 .golden.json
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenResult 一个页面的提取结果，提取失败时只有 Error
+type goldenResult struct {
+	Error   string   `json:"error,omitempty"`
+	Article *Article `json:"article,omitempty"`
+}
+
+func goldenPages(tb testing.TB) []string {
+	pages, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(pages) == 0 {
+		tb.Fatal("testdata 中没有页面")
+	}
+	return pages
+}
+
+func TestExtractArticleGolden(t *testing.T) {
+	for _, page := range goldenPages(t) {
+		t.Run(filepath.Base(page), func(t *testing.T) {
+			html, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := ExtractArticle(string(html))
+			got := goldenResult{Article: a}
+			if err != nil {
+				got.Error = err.Error()
+			}
+			golden := strings.TrimSuffix(page, ".html") + ".golden.json"
+			if *update {
+				data, err := json.MarshalIndent(got, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(golden, append(data, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			data, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want goldenResult
+			if err = json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			// Score 是浮点数求和的结果，只比较到足够的精度
+			if got.Article != nil && want.Article != nil {
+				if math.Abs(got.Article.Score-want.Article.Score) > 1e-9*math.Abs(want.Article.Score) {
+					t.Fatalf("Score 期望 %v, 得到 %v", want.Article.Score, got.Article.Score)
+				}
+				got.Article.Score, want.Article.Score = 0, 0
+			}
+			// 按 json 比较，忽略时区指针和未导出的字段
+			g, _ := json.MarshalIndent(got, "", "  ")
+			w, _ := json.MarshalIndent(want, "", "  ")
+			if string(g) != string(w) {
+				t.Fatalf("和 %s 不一致:\n%s", golden, g)
+			}
+		})
+	}
+}
+
+// longPage 生成一个有 n 段正文、导航和推荐链接的长页面
+func longPage(n int) string {
+	var sb strings.Builder
+	sb.WriteString("<html><head><title>长页面_示例网</title></head><body>\n<div class=\"nav\">\n")
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&sb, "\t<a href=\"/c/%d\">频道%d</a>\n", i, i)
+	}
+	sb.WriteString("</div>\n<div class=\"main\">\n\t<h1>长页面</h1>\n\t<div class=\"content\">\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "\t\t<p>　　第%d段，这是一段足够长的正文内容， 包含  多个空格\t和制表符，用来测试 <b>提取</b> 的性能。<a href=\"/t/%d\">标签%d</a></p>\n", i, i, i)
+		if i%20 == 0 {
+			fmt.Fprintf(&sb, "\t\t<div class=\"ad\">\n\t\t\t<a href=\"/ad/%d\">广告%d</a>\n\t\t\t<a href=\"/ad/%d\">广告</a>\n\t\t</div>\n", i, i, i+1)
+		}
+	}
+	sb.WriteString("\t</div>\n\t<div class=\"related\">\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&sb, "\t\t<div><a href=\"/a/%d\">推荐文章%d</a></div>\n", i, i)
+	}
+	sb.WriteString("\t</div>\n</div>\n</body></html>")
+	return sb.String()
+}
+
+func BenchmarkExtractArticle(b *testing.B) {
+	for _, page := range goldenPages(b) {
+		html, err := os.ReadFile(page)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(filepath.Base(page), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				// forum_table.html 没有正文，也要统计耗时
+				_, _ = ExtractArticle(string(html))
+			}
+		})
+	}
+}
+
+func BenchmarkExtractArticleLong(b *testing.B) {
+	html := longPage(2000)
+	b.SetBytes(int64(len(html)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ExtractArticle(html); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCalculate(b *testing.B) {
+	html := longPage(2000)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := calculate(html, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package khtmlextract
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Image 正文中的图片
+type Image struct {
+	// 绝对地址，没有设置 ExtractOptions.PageURL 并且没有 <base> 时为原始地址
+	URL string
+	Alt string
+	// 来自 width、height 属性，没有时为 0
+	Width  int
+	Height int
+	// 图片在 ContentText 中的位置，按字符计算
+	Position int
+	// DownloadImages 之后的本地路径
+	LocalPath string
+	// 图片属性中的原始地址，用来替换 ContentHTML
+	raw string
+}
+
+// lazyImageAttrs 懒加载的图片地址常用的属性
+var lazyImageAttrs = []string{"data-src", "data-original", "data-lazy-src", "data-actualsrc"}
+
+// imageSrc 依次使用懒加载属性、srcset 中最大的图片和 src，忽略 data: 图片
+func imageSrc(n *html.Node) string {
+	for _, key := range lazyImageAttrs {
+		if v := strings.TrimSpace(attr(n, key)); v != "" && !strings.HasPrefix(v, "data:") {
+			return v
+		}
+	}
+	for _, key := range []string{"data-srcset", "srcset"} {
+		if v := largestSrcset(attr(n, key)); v != "" {
+			return v
+		}
+	}
+	if v := strings.TrimSpace(attr(n, "src")); !strings.HasPrefix(v, "data:") {
+		return v
+	}
+	return ""
+}
+
+// largestSrcset 返回 srcset 中宽度或者倍数最大的地址
+func largestSrcset(srcset string) string {
+	best := ""
+	bestSize := -1.0
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "data:") {
+			continue
+		}
+		size := 1.0
+		if len(fields) > 1 {
+			d := fields[1]
+			if v, err := strconv.ParseFloat(d[:len(d)-1], 64); err == nil && (strings.HasSuffix(d, "w") || strings.HasSuffix(d, "x")) {
+				size = v
+			}
+		}
+		if size > bestSize {
+			best, bestSize = fields[0], size
+		}
+	}
+	return best
+}
+
+func attrInt(n *html.Node, key string) int {
+	v, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(attr(n, key)), "px"))
+	return v
+}
+
+// collectImages 按出现的顺序收集 sel 中的图片，Position 按和 ContentText 相同的方式计算
+func collectImages(sel *goquery.Selection, base *url.URL) []Image {
+	var images []Image
+	var pos textPosition
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			pos.write(n.Data)
+			return
+		case html.ElementNode:
+			if n.Data != "img" {
+				break
+			}
+			raw := imageSrc(n)
+			if raw == "" {
+				return
+			}
+			u := raw
+			if base != nil {
+				if abs, err := base.Parse(raw); err == nil {
+					u = abs.String()
+				}
+			}
+			images = append(images, Image{
+				URL:      u,
+				Alt:      strings.TrimSpace(attr(n, "alt")),
+				Width:    attrInt(n, "width"),
+				Height:   attrInt(n, "height"),
+				Position: pos.position(),
+				raw:      raw,
+			})
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range sel.Nodes {
+		walk(n)
+	}
+	return images
+}
+
+// textPosition 统计已经写入的文字经过 clearTxt 之后的字符数。
+// clearTxt 只合并 ' '、'\t'、'\n' 组成的空白段，每一段互不影响，所以只需要保留最后一段还没有结束的空白
+type textPosition struct {
+	n       int
+	pending []byte
+}
+
+func (p *textPosition) write(s string) {
+	for len(s) > 0 {
+		if isClearSpace(s[0]) {
+			p.pending = append(p.pending, s[0])
+			s = s[1:]
+			continue
+		}
+		p.n = p.position()
+		p.pending = p.pending[:0]
+		_, size := utf8.DecodeRuneInString(s)
+		p.n++
+		s = s[size:]
+	}
+}
+
+func (p *textPosition) position() int {
+	return p.n + len(p.pending) - spaceCut(string(p.pending))
+}
+
+// ImageDownloader 下载图片并返回本地路径，*kimgd.ImageDownloaderImpl 实现了这个接口
+type ImageDownloader interface {
+	Download(url string, dir string, prefix string) (string, error)
+}
+
+// DownloadImages 通过 downloader 把正文中的图片下载到 dir，文件名使用 prefix 作为前缀，
+// 并把 ContentHTML 和 ContentMarkdown 中图片的地址替换成本地路径。下载失败的图片保持原来的地址，返回所有的错误。
+// 只有 ExtractArticle 和 ExtractPaginatedArticle 返回的 Article 会替换 ContentHTML
+func (a *Article) DownloadImages(downloader ImageDownloader, dir string, prefix string) error {
+	var errs []error
+	local := map[string]string{}
+	for i := range a.Images {
+		img := &a.Images[i]
+		p, ok := local[img.URL]
+		if !ok {
+			var err error
+			p, err = downloader.Download(img.URL, dir, prefix)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("download %s: %w", img.URL, err))
+				continue
+			}
+			local[img.URL] = p
+		}
+		img.LocalPath = p
+	}
+	if len(local) == 0 {
+		return errors.Join(errs...)
+	}
+
+	byRaw := map[string]string{}
+	for _, img := range a.Images {
+		if img.LocalPath != "" {
+			byRaw[img.raw] = img.LocalPath
+			// 和 toMarkdown 中图片的格式一致，不会替换指向同一个地址的普通链接
+			a.ContentMarkdown = strings.ReplaceAll(a.ContentMarkdown, "!["+img.Alt+"]("+img.URL+")", "!["+img.Alt+"]("+img.LocalPath+")")
+		}
+	}
+	if len(a.content) > 0 {
+		// 和 merge 一样用换行连接每页的正文
+		var sb strings.Builder
+		var err error
+		for i, n := range a.content {
+			rewriteImages(n, byRaw)
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			if err = html.Render(&sb, n); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+		if err == nil {
+			a.ContentHTML = sb.String()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rewriteImages 把 n 下 img 的地址替换成 local 中的路径，并去掉懒加载和 srcset 属性
+func rewriteImages(n *html.Node, local map[string]string) {
+	if n.Type == html.ElementNode && n.Data == "img" {
+		if p, ok := local[imageSrc(n)]; ok {
+			attrs := n.Attr[:0]
+			for _, a := range n.Attr {
+				if a.Key == "src" || a.Key == "srcset" || a.Key == "data-srcset" || slices.Contains(lazyImageAttrs, a.Key) {
+					continue
+				}
+				attrs = append(attrs, a)
+			}
+			n.Attr = append(attrs, html.Attribute{Key: "src", Val: p})
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		rewriteImages(c, local)
+	}
+}
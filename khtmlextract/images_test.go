@@ -0,0 +1,137 @@
+package khtmlextract
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/kevin-zx/kbase/kimgd"
+	"golang.org/x/net/html"
+)
+
+func TestArticleImages(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer ts.Close()
+
+	html := strings.Replace(optionsTestHTML, "<p>市场人士认为",
+		`<p><img src="data:image/gif;base64,xx" data-src="/img/lazy.png" alt="懒加载" width="640px" height="480"></p>
+<p><img src="/img/small.png" srcset="/img/small.png 320w, /img/large.png 1280w, /img/mid.png 640w"></p>
+<p>市场人士认为`, 1)
+	a, err := ExtractArticleWithOptions(html, ExtractOptions{PageURL: ts.URL + "/news/1.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Images) != 2 {
+		t.Fatalf("应该有两张图片: %+v", a.Images)
+	}
+	first := a.Images[0]
+	if first.URL != ts.URL+"/img/lazy.png" || first.Alt != "懒加载" || first.Width != 640 || first.Height != 480 {
+		t.Fatalf("第一张图片不对: %+v", first)
+	}
+	if a.Images[1].URL != ts.URL+"/img/large.png" {
+		t.Fatalf("srcset 应该使用最大的图片: %+v", a.Images[1])
+	}
+	if before := []rune(a.ContentText)[:first.Position]; !strings.HasSuffix(string(before), "巩固经济回升向好的态势。\n") {
+		t.Fatalf("图片位置不对: %q", string(before))
+	}
+
+	dir := t.TempDir()
+	if err = a.DownloadImages(kimgd.NewImageDownloader(), dir, "a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range a.Images {
+		if _, err = os.Stat(img.LocalPath); err != nil {
+			t.Fatalf("图片没有下载: %+v", img)
+		}
+		if !strings.Contains(a.ContentHTML, `src="`+img.LocalPath+`"`) || !strings.Contains(a.ContentMarkdown, "]("+img.LocalPath+")") {
+			t.Fatalf("地址没有替换: %s\n%s", a.ContentHTML, a.ContentMarkdown)
+		}
+	}
+	if strings.Contains(a.ContentHTML, "srcset") || strings.Contains(a.ContentHTML, "data-src") {
+		t.Fatalf("懒加载和 srcset 属性应该去掉: %s", a.ContentHTML)
+	}
+}
+
+// stubDownloader 不访问网络，返回 dir 下和图片同名的路径
+type stubDownloader struct{}
+
+func (stubDownloader) Download(url string, dir string, prefix string) (string, error) {
+	return dir + "/" + prefix + path.Base(url), nil
+}
+
+func TestDownloadImagesInTable(t *testing.T) {
+	a := &Article{}
+	html := `<html><body><table><tr><td>
+<p>这是一段表格中的正文，内容足够长。这是一段表格中的正文，内容足够长。这是一段表格中的正文，内容足够长。</p>
+<p><img src="https://a.com/1.png" alt="图"></p>
+<p><a href="https://a.com/1.png">查看原图</a></p>
+</td></tr></table></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := doc.Find("td")
+	a.Images = collectImages(td, nil)
+	a.ContentMarkdown = toMarkdown(td, nil)
+	a.ContentHTML, _ = goquery.OuterHtml(td)
+	a.content = td.Nodes
+	if err = a.DownloadImages(stubDownloader{}, "/tmp/img", "a_"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a.ContentHTML, "<td>") || !strings.Contains(a.ContentHTML, `<img alt="图" src="/tmp/img/a_1.png"/>`) {
+		t.Fatalf("表格中的正文不应该丢失: %s", a.ContentHTML)
+	}
+	if !strings.Contains(a.ContentMarkdown, "![图](/tmp/img/a_1.png)") || !strings.Contains(a.ContentMarkdown, "[查看原图](https://a.com/1.png)") {
+		t.Fatalf("只应该替换图片的地址: %s", a.ContentMarkdown)
+	}
+}
+
+func TestImagePosition(t *testing.T) {
+	src := `<div>  开头 \t
+ <p>第一段  <b> 加粗 </b>
+</p><img src="1.png">
+<p>
+\t\t第二段</p>  \n  <img src="2.png"><span>  </span><img src="3.png"> 结尾</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	div := doc.Find("div")
+	images := collectImages(div, nil)
+	if len(images) != 3 {
+		t.Fatalf("应该有 3 张图片: %+v", images)
+	}
+	// 和按图片之前的全部文字计算的结果一致
+	for _, img := range images {
+		var sb strings.Builder
+		textBefore(div.Get(0), img.raw, &sb)
+		if want := utf8.RuneCountInString(strings.ReplaceAll(clearTxt(sb.String()), "\n\n", "\n")); img.Position != want {
+			t.Fatalf("%s 的位置期望 %d, 得到 %d", img.raw, want, img.Position)
+		}
+	}
+}
+
+// textBefore 把 n 中 src 为 src 的图片之前的文字写入 sb，找到图片时返回 true
+func textBefore(n *html.Node, src string, sb *strings.Builder) bool {
+	if n.Type == html.TextNode {
+		sb.WriteString(n.Data)
+		return false
+	}
+	if n.Type == html.ElementNode && n.Data == "img" && attr(n, "src") == src {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if textBefore(c, src, sb) {
+			return true
+		}
+	}
+	return false
+}
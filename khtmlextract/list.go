@@ -0,0 +1,252 @@
+package khtmlextract
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+)
+
+// ListRecord 列表页中的一条记录
+type ListRecord struct {
+	Title string
+	// 设置了 ListOptions.PageURL 或者有 <base> 时为绝对地址
+	URL     string
+	Snippet string
+	Image   string
+	// 记录中日期的原始文字
+	Date        string
+	PublishedAt time.Time
+}
+
+// ListResult 列表页的提取结果
+type ListResult struct {
+	// 记录的选择器，如 "div#main > ul.news > li"，可以通过 ListOptions.Selector 用在同一个网站的其它页面
+	Selector string
+	Records  []ListRecord
+}
+
+// ListOptions ExtractListWithOptions 的选项
+type ListOptions struct {
+	// 页面地址，用来把链接和图片转成绝对地址
+	PageURL string
+	// 不为空时直接用这个选择器选取记录，不再自动识别
+	Selector string
+	// 自动识别时最少的记录数，默认 3
+	MinRecords int
+	// 没有时区的日期按这个时区解析，默认 Asia/Shanghai
+	Location *time.Location
+}
+
+// ExtractList 识别 html 中重复出现的兄弟结点，比如搜索结果、商品列表和新闻列表，提取成记录
+func ExtractList(html string) (*ListResult, error) {
+	return ExtractListWithOptions(html, ListOptions{})
+}
+
+// ExtractListWithOptions 按 opts 提取列表
+func ExtractListWithOptions(html string, opts ListOptions) (*ListResult, error) {
+	doc, err := removeScriptAndStyle(html)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MinRecords <= 0 {
+		opts.MinRecords = 3
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = defaultLocation
+	}
+	selector := opts.Selector
+	if selector == "" {
+		selector = findListSelector(doc, opts.MinRecords)
+		if selector == "" {
+			return nil, fmt.Errorf("can't find list content")
+		}
+	}
+	base := htmlutil.PageBase(doc, opts.PageURL)
+	res := &ListResult{Selector: selector}
+	doc.Find(selector).Each(func(_ int, item *goquery.Selection) {
+		if r, ok := listRecord(item, base, loc); ok {
+			res.Records = append(res.Records, r)
+		}
+	})
+	return res, nil
+}
+
+// listGroup 同一个父结点下结构相同的子结点
+type listGroup struct {
+	parent *goquery.Selection
+	items  []*goquery.Selection
+	score  float64
+}
+
+// findListSelector 在所有结点中找到得分最高的一组重复子结点，返回它们的选择器。
+// 得分 = 数量 * log(平均字数) * 带链接的比例，导航栏这种字数很少的链接组得分会很低
+func findListSelector(doc *goquery.Document, minRecords int) string {
+	body := doc.Find("body")
+	if body.Length() == 0 {
+		return ""
+	}
+	counts := countElements(body.Get(0))
+	var best *listGroup
+	doc.Find("body, body *").Each(func(_ int, parent *goquery.Selection) {
+		groups := map[string][]*goquery.Selection{}
+		var order []string
+		parent.Children().Each(func(_ int, child *goquery.Selection) {
+			sig := nodeSignature(child)
+			if _, ok := groups[sig]; !ok {
+				order = append(order, sig)
+			}
+			groups[sig] = append(groups[sig], child)
+		})
+		for _, sig := range order {
+			items := groups[sig]
+			if len(items) < minRecords {
+				continue
+			}
+			textLen, linked := 0, 0
+			for _, item := range items {
+				c := counts[item.Get(0)]
+				textLen += c.runes
+				if c.links > 0 {
+					linked++
+				}
+			}
+			avg := float64(textLen) / float64(len(items))
+			score := float64(len(items)) * math.Log(avg+1) * float64(linked) / float64(len(items))
+			if best == nil || score > best.score {
+				best = &listGroup{parent: parent, items: items, score: score}
+			}
+		}
+	})
+	if best == nil || best.score == 0 {
+		return ""
+	}
+	return selectorPath(best.parent) + " > " + nodeSignature(best.items[0])
+}
+
+// nodeSignature tag 加排过序的 class，如 li.item.news
+func nodeSignature(s *goquery.Selection) string {
+	sig := goquery.NodeName(s)
+	classes := strings.Fields(s.AttrOr("class", ""))
+	slices.Sort(classes)
+	for _, c := range slices.Compact(classes) {
+		sig += "." + cssEscape(c)
+	}
+	return sig
+}
+
+// selectorPath 从最近的有 id 的祖先（或者 html）到 s 的选择器，不使用 nth-child，方便用在同一个网站的其它页面
+func selectorPath(s *goquery.Selection) string {
+	var parts []string
+	for n := s; n.Length() > 0 && goquery.NodeName(n) != "#document"; n = n.Parent() {
+		if id := strings.TrimSpace(n.AttrOr("id", "")); id != "" && !strings.ContainsAny(id, " \t") {
+			parts = append(parts, goquery.NodeName(n)+"#"+cssEscape(id))
+			break
+		}
+		parts = append(parts, nodeSignature(n))
+	}
+	slices.Reverse(parts)
+	return strings.Join(parts, " > ")
+}
+
+// cssEscape 转义选择器中的特殊字符
+func cssEscape(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '-' || r == '_' || r >= 0x80 ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				fmt.Fprintf(&sb, "\\%x ", r)
+			} else {
+				sb.WriteRune(r)
+			}
+		default:
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// listRecord 标题优先使用 h1~h6 中的文字，其次是文字最长的链接
+func listRecord(item *goquery.Selection, base *url.URL, loc *time.Location) (ListRecord, bool) {
+	var r ListRecord
+	resolve := func(href string) string {
+		href = strings.TrimSpace(href)
+		if base == nil || href == "" {
+			return href
+		}
+		if u, err := base.Parse(href); err == nil {
+			return u.String()
+		}
+		return href
+	}
+
+	var link *goquery.Selection
+	if h := item.Find("h1, h2, h3, h4, h5, h6").First(); h.Length() > 0 {
+		r.Title = strings.TrimSpace(htmlutil.CollapseSpace(h.Text()))
+		if a := h.Find("a[href]").First(); a.Length() > 0 {
+			link = a
+		} else if h.ParentsFiltered("a[href]").Length() > 0 {
+			link = h.ParentsFiltered("a[href]").First()
+		}
+	}
+	if link == nil {
+		longest := -1
+		links := item.Find("a[href]")
+		if item.Is("a[href]") {
+			links = item
+		}
+		links.Each(func(_ int, a *goquery.Selection) {
+			if l := utf8.RuneCountInString(strings.TrimSpace(a.Text())); l > longest {
+				longest, link = l, a
+			}
+		})
+	}
+	if link != nil {
+		r.URL = resolve(link.AttrOr("href", ""))
+		if r.Title == "" {
+			r.Title = strings.TrimSpace(htmlutil.CollapseSpace(link.Text()))
+		}
+	}
+	if r.Title == "" {
+		r.Title = strings.TrimSpace(item.AttrOr("title", ""))
+	}
+
+	img := item.Find("img").First()
+	if item.Is("img") {
+		img = item
+	}
+	if img.Length() > 0 {
+		r.Image = resolve(imageSrc(img.Get(0)))
+	}
+
+	text := strings.TrimSpace(htmlutil.CollapseSpace(item.Text()))
+	if t := item.Find("time").First(); t.Length() > 0 {
+		r.Date = strings.TrimSpace(t.AttrOr("datetime", t.Text()))
+	} else if m := htmlutil.BareDateRe.FindString(text); m != "" {
+		r.Date = m
+	}
+	if r.Date != "" {
+		r.PublishedAt, _ = htmlutil.ParseTime(r.Date, loc)
+	}
+
+	snippet := text
+	for _, s := range []string{r.Title, r.Date} {
+		if s != "" {
+			snippet = strings.Replace(snippet, s, "", 1)
+		}
+	}
+	r.Snippet = strings.TrimSpace(htmlutil.CollapseSpace(snippet))
+	return r, r.Title != "" || r.URL != ""
+}
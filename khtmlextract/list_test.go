@@ -0,0 +1,57 @@
+package khtmlextract
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listTestHTML(prefix string, n int) string {
+	var items strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&items, `<li class="item news"><img data-src="/img/%[1]s%[2]d.jpg" src="data:image/gif;base64,xx">
+<h3><a href="/news/%[1]s%[2]d.html">%[1]s新闻标题%[2]d</a></h3>
+<p>这是第%[2]d条新闻的摘要，介绍新闻的主要内容。</p><span>2024-01-0%[2]d 10:00</span></li>`, prefix, i)
+	}
+	return `<html><body>
+<div class="nav"><a href="/">首页</a><a href="/a">财经</a><a href="/b">科技</a><a href="/c">体育</a><a href="/d">娱乐</a></div>
+<div id="main"><div class="box"><ul class="list">` + items.String() + `</ul></div></div>
+<div class="footer"><a href="/about">关于我们</a><a href="/contact">联系我们</a><a href="/jobs">加入我们</a></div>
+</body></html>`
+}
+
+func TestExtractList(t *testing.T) {
+	res, err := ExtractListWithOptions(listTestHTML("a", 4), ListOptions{PageURL: "https://a.com/list/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Selector != "div#main > div.box > ul.list > li.item.news" || len(res.Records) != 4 {
+		t.Fatalf("选择器或者记录数不对: %s %d", res.Selector, len(res.Records))
+	}
+	r := res.Records[1]
+	want := ListRecord{
+		Title:       "a新闻标题2",
+		URL:         "https://a.com/news/a2.html",
+		Snippet:     "这是第2条新闻的摘要，介绍新闻的主要内容。",
+		Image:       "https://a.com/img/a2.jpg",
+		Date:        "2024-01-02 10:00",
+		PublishedAt: time.Date(2024, 1, 2, 10, 0, 0, 0, defaultLocation),
+	}
+	if r != want {
+		t.Fatalf("记录不对:\n%+v\n%+v", r, want)
+	}
+
+	// 选择器可以用在其它页面
+	other, err := ExtractListWithOptions(listTestHTML("b", 6), ListOptions{Selector: res.Selector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Records) != 6 || other.Records[5].URL != "/news/b6.html" {
+		t.Fatalf("复用选择器的结果不对: %+v", other.Records)
+	}
+
+	if _, err = ExtractList("<html><body><p>没有列表</p></body></html>"); err == nil {
+		t.Fatal("没有列表时应该返回错误")
+	}
+}
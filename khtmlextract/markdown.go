@@ -0,0 +1,300 @@
+package khtmlextract
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+	"golang.org/x/net/html"
+)
+
+// mdConverter 把正文结点转成 markdown，只保留结构，样式等属性都会丢掉
+type mdConverter struct {
+	// 为空时链接和图片保持原样
+	base *url.URL
+}
+
+// toMarkdown 把 sel 转成 markdown，base 用来把链接和图片转成绝对地址
+func toMarkdown(sel *goquery.Selection, base *url.URL) string {
+	c := &mdConverter{base: base}
+	var sb strings.Builder
+	for _, n := range sel.Nodes {
+		sb.WriteString(c.node(n))
+	}
+	return cleanMarkdown(sb.String())
+}
+
+func (c *mdConverter) children(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.node(child))
+	}
+	return sb.String()
+}
+
+func (c *mdConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if c.base == nil || href == "" {
+		return href
+	}
+	u, err := c.base.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.String()
+}
+
+func block(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return "\n\n" + s + "\n\n"
+}
+
+func (c *mdConverter) node(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return htmlutil.CollapseSpace(n.Data)
+	case html.ElementNode:
+	case html.DocumentNode:
+		return c.children(n)
+	default:
+		return ""
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript", "iframe", "head":
+		return ""
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		text := strings.TrimSpace(strings.ReplaceAll(c.children(n), "\n", " "))
+		if text == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+	case "br":
+		return "\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "strong", "b":
+		return wrapInline(c.children(n), "**")
+	case "em", "i":
+		return wrapInline(c.children(n), "*")
+	case "del", "s", "strike":
+		return wrapInline(c.children(n), "~~")
+	case "code":
+		return wrapInline(textOf(n), "`")
+	case "pre":
+		return c.pre(n)
+	case "a":
+		text := strings.TrimSpace(c.children(n))
+		href := c.resolve(attr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "javascript:") || text == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case "img":
+		src := c.resolve(imageSrc(n))
+		if src == "" {
+			return ""
+		}
+		return "![" + strings.TrimSpace(attr(n, "alt")) + "](" + src + ")"
+	case "ul", "ol":
+		return c.list(n, n.Data == "ol")
+	case "blockquote":
+		content := strings.TrimSpace(cleanMarkdown(c.children(n)))
+		if content == "" {
+			return ""
+		}
+		lines := strings.Split(content, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case "table":
+		return c.table(n)
+	case "p", "div", "section", "article", "header", "footer", "figure", "figcaption", "main", "aside", "dl", "dt", "dd", "center":
+		return block(c.children(n))
+	}
+	return c.children(n)
+}
+
+func wrapInline(s string, mark string) string {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return s
+	}
+	// 保留两边的空格，避免和前后的文字连在一起
+	prefix := s[:len(s)-len(strings.TrimLeft(s, " "))]
+	suffix := s[len(strings.TrimRight(s, " ")):]
+	return prefix + mark + t + mark + suffix
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// textOf 结点的原始文字，不处理空白
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textOf(c))
+	}
+	return sb.String()
+}
+
+// pre 代码块，class 中的 language-xxx 或者 lang-xxx 作为语言
+func (c *mdConverter) pre(n *html.Node) string {
+	lang := ""
+	classes := attr(n, "class")
+	if code := n.FirstChild; code != nil && code.Type == html.ElementNode && code.Data == "code" {
+		classes += " " + attr(code, "class")
+	}
+	for _, cls := range strings.Fields(classes) {
+		if l, ok := strings.CutPrefix(cls, "language-"); ok {
+			lang = l
+			break
+		}
+		if l, ok := strings.CutPrefix(cls, "lang-"); ok {
+			lang = l
+			break
+		}
+	}
+	code := strings.Trim(textOf(n), "\n")
+	return "\n\n```" + lang + "\n" + code + "\n```\n\n"
+}
+
+func (c *mdConverter) list(n *html.Node, ordered bool) string {
+	var items []string
+	i := 0
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+		i++
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(i) + ". "
+		}
+		content := strings.TrimSpace(cleanMarkdown(c.children(li)))
+		// 子列表等多行内容缩进到标记之后，去掉空行保持紧凑列表
+		var lines []string
+		for j, l := range strings.Split(content, "\n") {
+			if l == "" {
+				continue
+			}
+			if j > 0 {
+				l = strings.Repeat(" ", len(marker)) + l
+			}
+			lines = append(lines, l)
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	return "\n\n" + strings.Join(items, "\n") + "\n\n"
+}
+
+func (c *mdConverter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "tr":
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := strings.TrimSpace(htmlutil.CollapseSpace(cleanMarkdown(c.children(cell))))
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			case "table":
+				// 嵌套的表格按文字处理
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n")
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// cleanMarkdown 去掉行尾空格和多余的空行，代码块中的内容保持不变
+func cleanMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	inCode := false
+	blank := false
+	for _, l := range lines {
+		if inCode {
+			out = append(out, l)
+			if strings.HasPrefix(l, "```") {
+				inCode = false
+			}
+			continue
+		}
+		l = strings.TrimRight(l, " ")
+		if strings.TrimSpace(l) == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		if strings.HasPrefix(strings.TrimLeft(l, " "), "```") {
+			inCode = true
+			l = strings.TrimLeft(l, " ")
+		} else if !isListLine(l) {
+			l = strings.TrimLeft(l, " ")
+		}
+		out = append(out, l)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+var listLineRe = regexp.MustCompile(`^ *(?:[-*] |\d+\. |> )`)
+
+// isListLine 列表的缩进需要保留
+func isListLine(l string) bool {
+	return strings.HasPrefix(l, " ") && listLineRe.MatchString(l)
+}
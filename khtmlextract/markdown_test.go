@@ -0,0 +1,40 @@
+package khtmlextract
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestToMarkdown(t *testing.T) {
+	html := `<div class="content" style="color:red">
+<h2 style="font-size:20px">小标题</h2>
+<p>第一段 <b>加粗</b> 和 <a href="/a.html" style="color:blue">链接</a>。<br>换行</p>
+<p><img data-src="img/1.jpg" src="data:image/gif;base64,xx" alt="图一"></p>
+<ul><li>一</li><li>二<ol><li>二.1</li></ol></li></ul>
+<blockquote><p>引用</p></blockquote>
+<pre><code class="language-go">func main() {
+    fmt.Println("hi")
+}</code></pre>
+<table><tr><th>名称</th><th>价格</th></tr><tr><td>苹果</td><td>5|6</td></tr></table>
+<script>alert(1)</script>
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://a.com/news/1.html")
+	got := toMarkdown(doc.Find(".content"), base)
+	want := "## 小标题\n\n" +
+		"第一段 **加粗** 和 [链接](https://a.com/a.html)。\n换行\n\n" +
+		"![图一](https://a.com/news/img/1.jpg)\n\n" +
+		"- 一\n- 二\n  1. 二.1\n\n" +
+		"> 引用\n\n" +
+		"```go\nfunc main() {\n    fmt.Println(\"hi\")\n}\n```\n\n" +
+		"| 名称 | 价格 |\n| --- | --- |\n| 苹果 | 5\\|6 |"
+	if got != want {
+		t.Fatalf("markdown 不对:\n%s\n----\n%s", got, want)
+	}
+}
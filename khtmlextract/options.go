@@ -0,0 +1,109 @@
+package khtmlextract
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TitleStrategy 标题的提取方式
+type TitleStrategy int
+
+const (
+	// TitleDefault 第一个 H1，没有时使用 <title>
+	TitleDefault TitleStrategy = iota
+	// TitleOpenGraph 优先使用 og:title，没有时同 TitleDefault
+	TitleOpenGraph
+	// TitleH1 同 TitleDefault
+	TitleH1
+	// TitleTrimSiteName 使用 <title> 并去掉 "_站点名"、" - 站点名" 这样的后缀，没有 <title> 时同 TitleDefault
+	TitleTrimSiteName
+)
+
+// ExtractOptions ExtractArticleWithOptions 的选项，零值和 ExtractArticle 的行为一致
+type ExtractOptions struct {
+	// 除了 script 和 style 之外，计算正文之前要删除的结点，如 ".comment"、"#footer"
+	NoiseSelectors []string
+	// 正文中以这些短语开头的短结点会被删除，为空时按 Language 使用默认值，中文为 "上一篇"、"下一篇"
+	BoilerplatePhrases []string
+	// 正文的最少字数，少于时返回错误，0 表示不限制
+	MinTextLength int
+	Title         TitleStrategy
+	// 语言提示，如 "zh"、"en"，为空时按中文处理
+	Language string
+	// 没有时区的发布时间按这个时区解析，默认 Asia/Shanghai
+	Location *time.Location
+	// 页面地址，用来把 markdown 中的链接和图片转成绝对地址，为空时只使用 <base>
+	PageURL string
+}
+
+func (o *ExtractOptions) location() *time.Location {
+	if o.Location != nil {
+		return o.Location
+	}
+	return defaultLocation
+}
+
+var defaultBoilerplatePhrases = map[string][]string{
+	"zh": {"上一篇", "下一篇"},
+	"en": {"Previous", "Next", "Related"},
+}
+
+func (o *ExtractOptions) boilerplatePhrases() []string {
+	if len(o.BoilerplatePhrases) > 0 {
+		return o.BoilerplatePhrases
+	}
+	lang := strings.ToLower(o.Language)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if phrases, ok := defaultBoilerplatePhrases[lang]; ok {
+		return phrases
+	}
+	return defaultBoilerplatePhrases["zh"]
+}
+
+// titleSeparators <title> 中标题和站点名之间常见的分隔符
+var titleSeparators = []string{" - ", " | ", "|", "_", " — ", " – ", "--"}
+
+// extractTitle 按 strategy 提取标题
+func extractTitle(doc *goquery.Document, strategy TitleStrategy) string {
+	switch strategy {
+	case TitleOpenGraph:
+		if t := strings.TrimSpace(doc.Find(`meta[property="og:title"]`).AttrOr("content", "")); t != "" {
+			return t
+		}
+	case TitleTrimSiteName:
+		if t := strings.TrimSpace(doc.Find("title").First().Text()); t != "" {
+			siteName := strings.TrimSpace(doc.Find(`meta[property="og:site_name"]`).AttrOr("content", ""))
+			return trimSiteName(t, siteName)
+		}
+	}
+	title := getClearTxt(doc.Find("H1"))
+	if title == "" {
+		title = doc.Find("title").Text()
+	}
+	return title
+}
+
+// trimSiteName 去掉标题中的站点名后缀，知道站点名时只去掉站点名
+func trimSiteName(title string, siteName string) string {
+	if siteName != "" {
+		for _, sep := range titleSeparators {
+			if t, ok := strings.CutSuffix(title, sep+siteName); ok && strings.TrimSpace(t) != "" {
+				return strings.TrimSpace(t)
+			}
+		}
+		return title
+	}
+	// 站点名在最后一个分隔符后面，不管它是哪一种分隔符
+	cut := 0
+	for _, sep := range titleSeparators {
+		cut = max(cut, strings.LastIndex(title, sep))
+	}
+	if cut > 0 {
+		return strings.TrimSpace(title[:cut])
+	}
+	return title
+}
@@ -0,0 +1,77 @@
+package khtmlextract
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const optionsTestHTML = `<html><head>
+<title>央行发布新政策_财经频道_示例网</title>
+<meta property="og:title" content="央行发布新政策">
+<meta property="og:site_name" content="示例网">
+</head><body>
+<div id="nav"><a href="/">首页</a><a href="/news">新闻</a><a href="/tech">科技</a></div>
+<div class="main">
+<h1>央行发布新政策（附全文）</h1>
+<div class="content">
+<p>中国人民银行今日发布通知，决定下调金融机构存款准备金率，释放长期资金约一万亿元，以支持实体经济发展。</p>
+<p>央行有关负责人表示，此次降准是为了保持流动性合理充裕，促进综合融资成本稳中有降，巩固经济回升向好的态势。</p>
+<p>市场人士认为，降准将有效缓解银行负债端压力，增强银行信贷投放能力，对于稳定市场预期具有重要意义。</p>
+<p class="ad">广告：点击领取理财新人礼包，年化收益高达百分之八，名额有限，先到先得，详情请咨询客服。</p>
+<p>Next: 相关阅读</p>
+</div>
+</div>
+</body></html>`
+
+func TestExtractArticleWithOptions(t *testing.T) {
+	def, err := ExtractArticle(optionsTestHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero, err := ExtractArticleWithOptions(optionsTestHTML, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(def, zero) {
+		t.Fatalf("零值选项应该和 ExtractArticle 一致:\n%+v\n%+v", def, zero)
+	}
+	if def.Title != "央行发布新政策（附全文）" || !strings.Contains(def.ContentText, "广告") {
+		t.Fatalf("默认结果不对: %+v", def)
+	}
+
+	a, err := ExtractArticleWithOptions(optionsTestHTML, ExtractOptions{
+		NoiseSelectors: []string{".ad"},
+		Title:          TitleOpenGraph,
+		Language:       "en",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Title != "央行发布新政策" || strings.Contains(a.ContentText, "广告") || strings.Contains(a.ContentText, "相关阅读") {
+		t.Fatalf("选项没有生效: %+v", a)
+	}
+
+	_, err = ExtractArticleWithOptions(optionsTestHTML, ExtractOptions{MinTextLength: 10000})
+	if err == nil {
+		t.Fatal("正文太短时应该返回错误")
+	}
+}
+
+func TestTrimSiteName(t *testing.T) {
+	cases := []struct {
+		title, site, want string
+	}{
+		{"央行发布新政策_财经频道_示例网", "示例网", "央行发布新政策_财经频道"},
+		{"央行发布新政策_财经频道_示例网", "", "央行发布新政策_财经频道"},
+		{"Title - Site", "", "Title"},
+		{"A - B | Site", "", "A - B"},
+		{"Title | Site", "Other", "Title | Site"},
+		{"没有站点名", "", "没有站点名"},
+	}
+	for _, c := range cases {
+		if got := trimSiteName(c.title, c.site); got != c.want {
+			t.Fatalf("%q %q: 期望 %q, 得到 %q", c.title, c.site, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package khtmlextract
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+)
+
+// Link 页面中的链接
+type Link struct {
+	// 绝对地址，不包含 #fragment
+	URL  string
+	Text string
+	Rel  string
+	// 和页面是否是同一个站点，忽略 www. 前缀和默认端口
+	Internal bool
+}
+
+// PageMeta 页面的链接和元信息
+type PageMeta struct {
+	// 页面地址，有 <base> 时为 base 地址
+	URL         string
+	Title       string
+	Description string
+	Canonical   string
+	Keywords    []string
+	// 发布时间的原始字符串，没有找到时为空
+	PublishedTime string
+	Author        string
+	// og:title 等 OpenGraph 字段，key 去掉 og: 前缀
+	OpenGraph map[string]string
+	// twitter:card 等 Twitter 字段，key 去掉 twitter: 前缀
+	Twitter map[string]string
+	// 解析之后的 JSON-LD，数组和 @graph 会展开
+	JSONLD []map[string]any
+	Links  []Link
+
+	// 解析相对地址的 base，没有时为 nil
+	base *url.URL
+	// 所有 meta 的内容，detectByline 使用
+	metas map[string]string
+	// <link rel=next> 的绝对地址，它不在 Links 中，nextPageURL 使用
+	nextLinks []string
+}
+
+// InternalLinks 站内链接的地址
+func (m *PageMeta) InternalLinks() []string {
+	return m.linkURLs(true)
+}
+
+// ExternalLinks 站外链接的地址
+func (m *PageMeta) ExternalLinks() []string {
+	return m.linkURLs(false)
+}
+
+func (m *PageMeta) linkURLs(internal bool) []string {
+	var urls []string
+	for _, l := range m.Links {
+		if l.Internal == internal {
+			urls = append(urls, l.URL)
+		}
+	}
+	return urls
+}
+
+// ExtractPageMeta 从 html 中提取链接和元信息，链接按 <base> 和 pageURL 转成绝对地址
+func ExtractPageMeta(html string, pageURL string) (*PageMeta, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+	return extractPageMeta(doc, pageURL)
+}
+
+func extractPageMeta(doc *goquery.Document, pageURL string) (*PageMeta, error) {
+	if _, err := url.Parse(pageURL); err != nil {
+		return nil, err
+	}
+	m := &PageMeta{
+		OpenGraph: map[string]string{},
+		Twitter:   map[string]string{},
+		base:      htmlutil.PageBase(doc, pageURL),
+	}
+	base := m.base
+	if base == nil {
+		base = &url.URL{}
+	}
+	m.URL = base.String()
+	m.Title, m.Description = articleInfo(doc, TitleDefault)
+
+	metas := metaContents(doc)
+	m.metas = metas
+	for k, v := range metas {
+		switch {
+		case strings.HasPrefix(k, "og:"):
+			m.OpenGraph[k[3:]] = v
+		case strings.HasPrefix(k, "twitter:"):
+			m.Twitter[k[8:]] = v
+		}
+	}
+	if kw := metas["keywords"]; kw != "" {
+		m.Keywords = splitKeywords(kw)
+	}
+	if href, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href"); ok {
+		m.Canonical = resolveURL(base, href)
+	}
+	if m.Canonical == "" && m.OpenGraph["url"] != "" {
+		m.Canonical = resolveURL(base, m.OpenGraph["url"])
+	}
+	m.JSONLD = parseJSONLD(doc)
+	m.PublishedTime = findPublishedTime(doc, metas, m.JSONLD)
+	m.Author = findAuthor(doc, metas, m.JSONLD)
+	m.Links = extractLinks(doc, base)
+	doc.Find(`link[rel~="next"][href]`).Each(func(_ int, l *goquery.Selection) {
+		if u := resolveURL(base, l.AttrOr("href", "")); u != "" {
+			m.nextLinks = append(m.nextLinks, u)
+		}
+	})
+	return m, nil
+}
+
+// metaContents 收集 meta 的 name、property 和 itemprop，key 转成小写，同一个 key 保留第一个
+func metaContents(doc *goquery.Document) map[string]string {
+	metas := map[string]string{}
+	doc.Find("meta[content]").Each(func(_ int, meta *goquery.Selection) {
+		content := strings.TrimSpace(meta.AttrOr("content", ""))
+		if content == "" {
+			return
+		}
+		for _, attr := range []string{"property", "name", "itemprop"} {
+			k := strings.ToLower(strings.TrimSpace(meta.AttrOr(attr, "")))
+			if k == "" {
+				continue
+			}
+			if _, ok := metas[k]; !ok {
+				metas[k] = content
+			}
+		}
+	})
+	return metas
+}
+
+func splitKeywords(s string) []string {
+	var keywords []string
+	for _, kw := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '，' || r == ';' || r == '；' || r == '、' || r == '|'
+	}) {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			keywords = append(keywords, kw)
+		}
+	}
+	return keywords
+}
+
+func parseJSONLD(doc *goquery.Document) []map[string]any {
+	var blocks []map[string]any
+	var add func(v any)
+	add = func(v any) {
+		switch t := v.(type) {
+		case []any:
+			for _, item := range t {
+				add(item)
+			}
+		case map[string]any:
+			if graph, ok := t["@graph"]; ok {
+				add(graph)
+				return
+			}
+			blocks = append(blocks, t)
+		}
+	}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var v any
+		// 格式不对的块直接忽略
+		if json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &v) == nil {
+			add(v)
+		}
+	})
+	return blocks
+}
+
+// jsonLDString 返回第一个有 key 的 JSON-LD 块中的值，对象取 name
+func jsonLDString(blocks []map[string]any, key string) string {
+	for _, b := range blocks {
+		if s := jsonLDValue(b[key]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func jsonLDValue(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]any:
+		return jsonLDValue(t["name"])
+	case []any:
+		var names []string
+		for _, item := range t {
+			if s := jsonLDValue(item); s != "" {
+				names = append(names, s)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+var publishedTimeMetas = []string{
+	"article:published_time", "og:published_time", "datepublished",
+	"pubdate", "publishdate", "publish_date", "publication_date", "date",
+}
+
+func findPublishedTime(doc *goquery.Document, metas map[string]string, jsonLD []map[string]any) string {
+	for _, k := range publishedTimeMetas {
+		if v := metas[k]; v != "" {
+			return v
+		}
+	}
+	if v := jsonLDString(jsonLD, "datePublished"); v != "" {
+		return v
+	}
+	if v, ok := doc.Find("time[datetime]").First().Attr("datetime"); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
+
+func findAuthor(doc *goquery.Document, metas map[string]string, jsonLD []map[string]any) string {
+	for _, k := range []string{"author", "article:author", "twitter:creator"} {
+		if v := metas[k]; v != "" {
+			return v
+		}
+	}
+	if v := jsonLDString(jsonLD, "author"); v != "" {
+		return v
+	}
+	return strings.TrimSpace(doc.Find(`[itemprop="author"], [rel="author"]`).First().Text())
+}
+
+func resolveURL(base *url.URL, href string) string {
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// extractLinks 只保留 http 和 https 链接，同一个地址只保留第一个
+func extractLinks(doc *goquery.Document, base *url.URL) []Link {
+	var links []Link
+	seen := map[string]bool{}
+	host := siteHost(base)
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		u, err := base.Parse(strings.TrimSpace(a.AttrOr("href", "")))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+		s := u.String()
+		if seen[s] {
+			return
+		}
+		seen[s] = true
+		links = append(links, Link{
+			URL:      s,
+			Text:     strings.TrimSpace(getClearTxt(a)),
+			Rel:      a.AttrOr("rel", ""),
+			Internal: siteHost(u) == host,
+		})
+	})
+	return links
+}
+
+// siteHost 去掉 www. 前缀和默认端口，端口不同的是不同的站点
+func siteHost(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	port := u.Port()
+	if port == "" || (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		return host
+	}
+	return host + ":" + port
+}
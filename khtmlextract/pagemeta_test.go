@@ -0,0 +1,76 @@
+package khtmlextract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractPageMeta(t *testing.T) {
+	html := `<html><head>
+<title>测试页面</title>
+<base href="/news/">
+<meta name="keywords" content="新闻，科技, 手机">
+<meta name="description" content="页面摘要">
+<meta property="og:title" content="OG 标题">
+<meta property="og:image" content="https://img.a.com/1.jpg">
+<meta name="twitter:card" content="summary">
+<meta property="article:published_time" content="2024-05-01T08:00:00+08:00">
+<link rel="canonical" href="/news/1.html">
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[{"@type":"NewsArticle","author":[{"@type":"Person","name":"张三"}]},{"@type":"WebSite"}]}</script>
+<script type="application/ld+json">{bad json</script>
+</head><body>
+<a href="2.html#top">下一篇</a>
+<a href="https://www.a.com/3.html">站内</a>
+<a href="//b.com/x" rel="nofollow">站外</a>
+<a href="javascript:void(0)">js</a>
+<a href="mailto:a@a.com">mail</a>
+<a href="2.html">重复</a>
+<a href="https://a.com:443/4.html">默认端口</a>
+<a href="https://a.com:8080/5.html">其它端口</a>
+</body></html>`
+	m, err := ExtractPageMeta(html, "https://a.com/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.URL != "https://a.com/news/" || m.Canonical != "https://a.com/news/1.html" {
+		t.Fatalf("base 或 canonical 不对: %s %s", m.URL, m.Canonical)
+	}
+	if m.Title != "测试页面" || m.Description != "页面摘要" {
+		t.Fatalf("title 或 description 不对: %s %s", m.Title, m.Description)
+	}
+	if !reflect.DeepEqual(m.Keywords, []string{"新闻", "科技", "手机"}) {
+		t.Fatalf("keywords 不对: %v", m.Keywords)
+	}
+	if m.OpenGraph["title"] != "OG 标题" || m.OpenGraph["image"] != "https://img.a.com/1.jpg" || m.Twitter["card"] != "summary" {
+		t.Fatalf("og 或 twitter 不对: %v %v", m.OpenGraph, m.Twitter)
+	}
+	if len(m.JSONLD) != 2 || m.JSONLD[0]["@type"] != "NewsArticle" {
+		t.Fatalf("JSON-LD 不对: %v", m.JSONLD)
+	}
+	if m.PublishedTime != "2024-05-01T08:00:00+08:00" || m.Author != "张三" {
+		t.Fatalf("发布时间或作者不对: %s %s", m.PublishedTime, m.Author)
+	}
+	want := []Link{
+		{URL: "https://a.com/news/2.html", Text: "下一篇", Internal: true},
+		{URL: "https://www.a.com/3.html", Text: "站内", Internal: true},
+		{URL: "https://b.com/x", Text: "站外", Rel: "nofollow"},
+		{URL: "https://a.com:443/4.html", Text: "默认端口", Internal: true},
+		{URL: "https://a.com:8080/5.html", Text: "其它端口"},
+	}
+	if !reflect.DeepEqual(m.Links, want) {
+		t.Fatalf("链接不对: %+v", m.Links)
+	}
+	if !reflect.DeepEqual(m.ExternalLinks(), []string{"https://b.com/x", "https://a.com:8080/5.html"}) {
+		t.Fatalf("站外链接不对: %v", m.ExternalLinks())
+	}
+}
+
+func TestExtractArticleMeta(t *testing.T) {
+	a, err := ExtractArticleWithOptions(optionsTestHTML, ExtractOptions{PageURL: "https://www.example.com/news/1.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Meta == nil || a.Meta.URL != "https://www.example.com/news/1.html" || a.Meta.Title != a.Title || len(a.Meta.InternalLinks()) != 3 {
+		t.Fatalf("文章应该带上页面的元信息: %+v", a.Meta)
+	}
+}
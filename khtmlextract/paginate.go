@@ -0,0 +1,103 @@
+package khtmlextract
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kevin-zx/kbase/kcrawl"
+)
+
+// defaultMaxPages ExtractPaginatedArticle 默认最多抓取的页数
+const defaultMaxPages = 10
+
+// nextPageTexts 下一页链接常见的文字，比较时忽略大小写和两边的空白
+var nextPageTexts = []string{"下一页", "下页", "下一頁", "next", "next page", "next »", "next ›", "下一页>", "下一页 >", "下一页»"}
+
+// ExtractPaginatedArticle 从 pageURL 开始提取文章，按 rel=next 和 "下一页" 链接依次抓取后面的页，把每页的正文按顺序合并成一篇文章。
+// 页面通过 crawler 获取，非 utf-8 的网站需要 crawler 设置 RawWithCharsetDecode。maxPages <= 0 时最多抓取 10 页，
+// 下一页的地址出现过或者不是同一个站点时停止。后面的页出错时返回已经合并的文章和错误
+func ExtractPaginatedArticle(ctx context.Context, crawler kcrawl.RawCrawler, pageURL string, maxPages int, opts ExtractOptions) (*Article, error) {
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	visited := map[string]bool{}
+	var article *Article
+	for page := 0; page < maxPages && pageURL != ""; page++ {
+		key, err := kcrawl.NormalizeURL(pageURL)
+		if err != nil {
+			return article, err
+		}
+		if visited[key] {
+			break
+		}
+		visited[key] = true
+
+		body, err := crawler.GetCtx(ctx, pageURL)
+		if err != nil {
+			return article, fmt.Errorf("fetch page %d %s: %w", page+1, pageURL, err)
+		}
+		pageOpts := opts
+		pageOpts.PageURL = pageURL
+		a, err := ExtractArticleWithOptions(string(body), pageOpts)
+		if err != nil {
+			return article, fmt.Errorf("extract page %d %s: %w", page+1, pageURL, err)
+		}
+		if article == nil {
+			article = a
+		} else {
+			article.merge(a)
+		}
+		pageURL = nextPageURL(a.Meta, pageURL)
+	}
+	return article, nil
+}
+
+// merge 把下一页的正文接到 a 后面，标题、摘要、发布时间等使用第一页的
+func (a *Article) merge(next *Article) {
+	offset := utf8.RuneCountInString(a.ContentText) + 1
+	a.ContentText += "\n" + next.ContentText
+	a.ContentHTML += "\n" + next.ContentHTML
+	a.ContentMarkdown += "\n\n" + next.ContentMarkdown
+	for _, img := range next.Images {
+		img.Position += offset
+		a.Images = append(a.Images, img)
+	}
+	a.content = append(a.content, next.content...)
+	a.TextLength = utf8.RuneCountInString(a.ContentText)
+}
+
+// nextPageURL 查找下一页的地址，优先使用 rel=next，没有时返回空
+func nextPageURL(meta *PageMeta, pageURL string) string {
+	current, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	current.Fragment = ""
+	current.RawFragment = ""
+	// Link 和 nextLinks 已经是不包含 #fragment 的绝对地址
+	accept := func(href string) bool {
+		u, err := url.Parse(href)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") &&
+			siteHost(u) == siteHost(current) && href != current.String()
+	}
+	for _, href := range meta.nextLinks {
+		if accept(href) {
+			return href
+		}
+	}
+	for _, l := range meta.Links {
+		if slices.Contains(strings.Fields(strings.ToLower(l.Rel)), "next") && accept(l.URL) {
+			return l.URL
+		}
+	}
+	for _, l := range meta.Links {
+		if slices.Contains(nextPageTexts, strings.ToLower(l.Text)) && accept(l.URL) {
+			return l.URL
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package khtmlextract
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kevin-zx/kbase/kcrawl"
+)
+
+func TestExtractPaginatedArticle(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		nav := `<div class="pager"><a href="/a.html?page=2">下一页</a></div>`
+		head := ""
+		switch page {
+		case "2":
+			head = `<link rel="next" href="/a.html?page=3">`
+			nav = ""
+		case "3":
+			// 最后一页的下一页指回第一页
+			nav = `<div class="pager"><a href="/a.html#top">下一页</a></div>`
+		}
+		var paras strings.Builder
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(&paras, "<p>第%s页第%d段，这是一段足够长的正文内容，用来让提取算法正确地识别出正文所在的结点，内容没有实际意义。</p>", page, i)
+		}
+		fmt.Fprintf(w, `<html><head><title>文章</title>%s</head><body><div id="nav"><a href="/">首页</a></div>
+<div class="main"><h1>分页文章</h1><div class="content">%s</div>%s</div></body></html>`, head, paras.String(), nav)
+	}))
+	defer ts.Close()
+
+	crawler := kcrawl.NewRawCrawler(0, nil)
+	a, err := ExtractPaginatedArticle(context.Background(), crawler, ts.URL+"/a.html", 0, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 3 {
+		t.Fatalf("应该抓取 3 页, 实际 %d 页", hits.Load())
+	}
+	for _, page := range []string{"第1页第1段", "第2页第3段", "第3页第2段"} {
+		if !strings.Contains(a.ContentText, page) || !strings.Contains(a.ContentMarkdown, page) || !strings.Contains(a.ContentHTML, page) {
+			t.Fatalf("缺少 %s: %s", page, a.ContentText)
+		}
+	}
+	if strings.Index(a.ContentText, "第2页") > strings.Index(a.ContentText, "第3页") || a.Title != "分页文章" {
+		t.Fatalf("合并的顺序不对: %s", a.ContentText)
+	}
+
+	hits.Store(0)
+	a, err = ExtractPaginatedArticle(context.Background(), crawler, ts.URL+"/a.html", 2, ExtractOptions{})
+	if err != nil || hits.Load() != 2 || strings.Contains(a.ContentText, "第3页") {
+		t.Fatalf("最多应该抓取 2 页: %v, 实际 %d 页", err, hits.Load())
+	}
+}
+
+func TestDownloadImagesPaginated(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		nav := ""
+		if page == "" {
+			page = "1"
+			nav = `<div class="pager"><a href="/a.html?page=2">下一页</a></div>`
+		}
+		fmt.Fprintf(w, `<html><body><div class="content">
+<p>第%[1]s页，这是一段足够长的正文内容，用来让提取算法正确地识别出正文所在的结点，内容没有实际意义。</p>
+<p><img src="/img%[1]s.png" alt="图%[1]s"></p>
+<p>第%[1]s页，这是另一段足够长的正文内容，用来让提取算法正确地识别出正文所在的结点，内容没有实际意义。</p>
+</div>%[2]s</body></html>`, page, nav)
+	}))
+	defer ts.Close()
+
+	a, err := ExtractPaginatedArticle(context.Background(), kcrawl.NewRawCrawler(0, nil), ts.URL+"/a.html", 0, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.DownloadImages(stubDownloader{}, "/tmp/img", "a_"); err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []string{"1", "2"} {
+		if !strings.Contains(a.ContentHTML, "第"+page+"页") || !strings.Contains(a.ContentHTML, `src="/tmp/img/a_img`+page+`.png"`) {
+			t.Fatalf("第 %s 页的正文或图片丢失: %s", page, a.ContentHTML)
+		}
+		if !strings.Contains(a.ContentMarkdown, "![图"+page+"](/tmp/img/a_img"+page+".png)") {
+			t.Fatalf("第 %s 页的图片没有替换: %s", page, a.ContentMarkdown)
+		}
+	}
+}
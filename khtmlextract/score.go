@@ -0,0 +1,323 @@
+package khtmlextract
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// scoredNode 有子元素的结点和它的信息，按先序排列
+type scoredNode struct {
+	node *html.Node
+	info NodeInfo
+}
+
+// calculate 解析 html 并计算每个结点的得分，见 scoreDoc
+func calculate(html string, noiseSelectors []string) (*goquery.Document, []scoredNode, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, scoreDoc(doc, noiseSelectors), nil
+}
+
+// scoreDoc 删除 doc 中的噪音结点，然后计算 body 下每个有子元素的结点的得分。
+// 整个 body 的文字只拼接一次，每个结点的 T、LT 通过它在拼接后的文字中的区间计算，Sb 自底向上累加，
+// 不再对每个结点调用 getClearTxt
+func scoreDoc(doc *goquery.Document, noiseSelectors []string) []scoredNode {
+	removeNoise(doc, noiseSelectors...)
+	body := doc.Find("body")
+	if body.Length() == 0 {
+		return nil
+	}
+	s := newScorer(body.Get(0))
+	s.computeInfo(body.Get(0))
+	nodes := s.nodes
+	if len(nodes) == 0 {
+		return nil
+	}
+	sum := 0.0
+	for i := range nodes {
+		info := &nodes[i].info
+		// 计算文本密度
+		// calculate text density
+		td := (float64(info.T-info.LT) / float64(info.TG-info.LTG+1)) * math.Log10(float64(info.TG-info.LTG+1))
+		info.TD = td
+		sum += td
+		info.SbD = float64(info.T-info.LT) / float64(info.Sb+1)
+	}
+	nodeInfoCount := float64(len(nodes))
+	avg := sum / nodeInfoCount
+
+	// 计算文本密度标准差
+	// calculate text density's standard deviation
+	sdp := 0.0
+	for i := range nodes {
+		sdp += math.Pow(nodes[i].info.TD-avg, 2) / (nodeInfoCount)
+	}
+	sd := math.Sqrt(sdp)
+
+	sdLog := math.Log(sd)
+	if sd == 0 {
+		sdLog = 0
+	}
+	for i := range nodes {
+		// 计算结点信息
+		// calculate node info score
+		// latex formula: score = \log_{}{SD}*ND_i*\log_{10}{(PNum_i)}*\log_{}{SbD_i}
+		info := &nodes[i].info
+		info.Score = sdLog * info.TD * math.Log(float64(info.PNum+1)) * math.Log(info.SbD+1)
+	}
+	return nodes
+}
+
+// wsRun text 中一段连续的 ' '、'\t'、'\n'，cut 为 clearTxt 之后减少的字节数。
+// clearTxt 只会替换这些字符，所以每一段的结果互不影响
+type wsRun struct {
+	start, end int
+	cut        int
+}
+
+// scorer 按先序遍历结点，pos 为当前结点的文字在 text 中的开始位置
+type scorer struct {
+	text string
+	runs []wsRun
+	// cum[i] 为前 i 段的 cut 之和
+	cum   []int
+	pos   int
+	nodes []scoredNode
+}
+
+func newScorer(root *html.Node) *scorer {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	s := &scorer{text: sb.String(), cum: []int{0}}
+	for i := 0; i < len(s.text); {
+		if !isClearSpace(s.text[i]) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(s.text) && isClearSpace(s.text[j]) {
+			j++
+		}
+		if j-i > 1 {
+			r := wsRun{start: i, end: j, cut: spaceCut(s.text[i:j])}
+			s.runs = append(s.runs, r)
+			s.cum = append(s.cum, s.cum[len(s.cum)-1]+r.cut)
+		}
+		i = j
+	}
+	return s
+}
+
+// computeInfo 和原来基于 goquery.Selection 的实现结果一致：
+// 没有子元素的结点只统计 a 和 p，不放入结果；有子元素的 p 不再遍历子结点
+func (s *scorer) computeInfo(n *html.Node) (info NodeInfo, punct int) {
+	start := s.pos
+	if !hasElementChild(n) {
+		punct = s.skip(n)
+		if n.Data == "a" {
+			info.LTG++
+			info.LT = s.clearLen(start, s.pos)
+		} else if n.Data == "p" {
+			info.PNum++
+		}
+		info.TG = 1
+		return info, punct
+	}
+
+	idx := len(s.nodes)
+	s.nodes = append(s.nodes, scoredNode{node: n})
+	if n.Data == "p" {
+		info.PNum++
+		punct = s.skip(n)
+	} else {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				punct += s.skip(c)
+				continue
+			}
+			childInfo, childPunct := s.computeInfo(c)
+			info.LT += childInfo.LT
+			info.TG += childInfo.TG
+			info.LTG += childInfo.LTG
+			info.PNum += childInfo.PNum
+			punct += childPunct
+		}
+	}
+	info.T = s.clearLen(start, s.pos)
+	if info.T > 0 {
+		info.Sb = punct
+	}
+	info.TG++
+	s.nodes[idx].info = info
+	return info, punct
+}
+
+// elemCount 元素的文字经过 clearTxt 之后的字符数，和它自己以及子孙中 a[href] 的数量
+type elemCount struct {
+	runes int
+	links int
+}
+
+// countElements 统计 root 和它下面每个元素的 elemCount。
+// 和 scoreDoc 一样整个 root 的文字只拼接一次，字符数通过 clearLen 计算，不再对每个结点调用 getClearTxt
+func countElements(root *html.Node) map[*html.Node]elemCount {
+	s := newScorer(root)
+	counts := make(map[*html.Node]elemCount)
+	runes := 0
+	var walk func(n *html.Node) int
+	walk = func(n *html.Node) int {
+		if n.Type == html.TextNode {
+			s.pos += len(n.Data)
+			runes += utf8.RuneCountInString(n.Data)
+			return 0
+		}
+		start, startRunes := s.pos, runes
+		links := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			links += walk(c)
+		}
+		if n.Type != html.ElementNode {
+			return links
+		}
+		if n.Data == "a" && slices.ContainsFunc(n.Attr, func(a html.Attribute) bool { return a.Key == "href" }) {
+			links++
+		}
+		// clearTxt 只删除单字节的空白，删除的字节数就是删除的字符数
+		cut := s.pos - start - s.clearLen(start, s.pos)
+		counts[n] = elemCount{runes: runes - startRunes - cut, links: links}
+		return links
+	}
+	walk(root)
+	return counts
+}
+
+// skip 跳过 n 下的所有文字，返回其中标点符号的数量
+func (s *scorer) skip(n *html.Node) int {
+	if n.Type == html.TextNode {
+		s.pos += len(n.Data)
+		return punctCount(n.Data)
+	}
+	punct := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		punct += s.skip(c)
+	}
+	return punct
+}
+
+// clearLen 等于 len(clearTxt(s.text[start:end]))
+func (s *scorer) clearLen(start, end int) int {
+	n := end - start
+	if n == 0 {
+		return 0
+	}
+	// [i, j) 为和区间相交的空白段
+	i := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].end > start })
+	j := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].start >= end })
+	if i >= j {
+		return n
+	}
+	n -= s.cum[j] - s.cum[i]
+	// 两端被截断的空白段单独计算
+	for _, k := range []int{i, j - 1} {
+		r := s.runs[k]
+		if r.start < start || r.end > end {
+			n += r.cut - spaceCut(s.text[max(r.start, start):min(r.end, end)])
+		}
+		if i == j-1 {
+			break
+		}
+	}
+	return n
+}
+
+func hasElementChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			return true
+		}
+	}
+	return false
+}
+
+func isClearSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n'
+}
+
+func punctCount(s string) int {
+	count := 0
+	for _, r := range s {
+		if unicode.IsPunct(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// spaceCut 返回只含 ' '、'\t'、'\n' 的 run 经过 clearTxt 之后减少的字节数，短的 run 不分配内存
+func spaceCut(run string) int {
+	if len(run) < 2 {
+		return 0
+	}
+	var buf [64]byte
+	b := buf[:0]
+	if len(run) > len(buf) {
+		b = make([]byte, 0, len(run))
+	}
+	b = append(b, run...)
+	// 和 clearTxt 中的替换顺序一致
+	for _, r := range [...][3]byte{
+		{' ', '\n', '\n'},
+		{'\n', '\t', '\n'},
+		{'\n', ' ', '\n'},
+		{' ', ' ', ' '},
+		{'\t', '\t', '\t'},
+		{'\n', '\n', '\n'},
+		{'\t', '\n', '\n'},
+	} {
+		for {
+			var replaced bool
+			b, replaced = replacePair(b, r[0], r[1], r[2])
+			if !replaced {
+				break
+			}
+		}
+	}
+	return len(run) - len(b)
+}
+
+// replacePair 和 strings.ReplaceAll 一样从左到右不重叠地把 x y 替换成 to，原地修改
+func replacePair(b []byte, x, y, to byte) ([]byte, bool) {
+	w := 0
+	replaced := false
+	for i := 0; i < len(b); i++ {
+		if i+1 < len(b) && b[i] == x && b[i+1] == y {
+			b[w] = to
+			w++
+			i++
+			replaced = true
+			continue
+		}
+		b[w] = b[i]
+		w++
+	}
+	return b[:w], replaced
+}
@@ -0,0 +1,82 @@
+package khtmlextract
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+func TestClearLen(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const chars = " \t\n\rab　"
+	for i := 0; i < 2000; i++ {
+		runes := make([]rune, rnd.Intn(120))
+		for k := range runes {
+			runes[k] = []rune(chars)[rnd.Intn(len([]rune(chars)))]
+		}
+		text := string(runes)
+		s := newScorer(&html.Node{Type: html.TextNode, Data: text})
+		for k := 0; k < 20; k++ {
+			start := rnd.Intn(len(text) + 1)
+			end := start + rnd.Intn(len(text)-start+1)
+			if got, want := s.clearLen(start, end), len(clearTxt(text[start:end])); got != want {
+				t.Fatalf("%q[%d:%d]: 期望 %d, 得到 %d", text, start, end, want, got)
+			}
+		}
+	}
+}
+
+func TestCalculateMatchesClearTxt(t *testing.T) {
+	for _, page := range goldenPages(t) {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, nodes, err := calculate(string(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range nodes {
+			txt := getClearTxt(doc.FindNodes(n.node))
+			sb := 0
+			for _, r := range txt {
+				if unicode.IsPunct(r) {
+					sb++
+				}
+			}
+			if n.info.T != len(txt) || n.info.Sb != sb {
+				t.Fatalf("%s <%s>: 期望 T=%d Sb=%d, 得到 T=%d Sb=%d", filepath.Base(page), n.node.Data, len(txt), sb, n.info.T, n.info.Sb)
+			}
+		}
+	}
+}
+
+func TestCountElementsMatchesClearTxt(t *testing.T) {
+	for _, page := range goldenPages(t) {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := removeScriptAndStyle(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := countElements(doc.Find("body").Get(0))
+		doc.Find("body, body *").Each(func(_ int, s *goquery.Selection) {
+			runes := utf8.RuneCountInString(getClearTxt(s))
+			links := s.Find("a[href]").Length()
+			if s.Is("a[href]") {
+				links++
+			}
+			if c := counts[s.Get(0)]; c.runes != runes || c.links != links {
+				t.Fatalf("%s <%s>: 期望 %d %d, 得到 %+v", filepath.Base(page), goquery.NodeName(s), runes, links, c)
+			}
+		})
+	}
+}
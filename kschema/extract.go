@@ -0,0 +1,230 @@
+package kschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+	"github.com/kevin-zx/kbase/kcrawl"
+)
+
+// FieldError 字段提取失败，Path 为 "comments.2.time" 这样的路径
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Extract 按 schema 提取 html，字段没有值时为 nil，列表字段没有值时为空列表。
+// 值的类型: string、int64、float64、bool、time.Time、[]any 和 map[string]any
+func Extract(html string, schema *Schema) (map[string]any, error) {
+	return ExtractWithURL(html, "", schema)
+}
+
+// ExtractWithURL 和 Extract 一样，url 类型的字段按 pageURL（或者页面中的 <base>）转成绝对地址
+func ExtractWithURL(html string, pageURL string, schema *Schema) (map[string]any, error) {
+	if err := schema.Compile(); err != nil {
+		return nil, err
+	}
+	c := schema.compiled
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+	root := doc.Selection
+	if c.selector != "" {
+		root = doc.Find(c.selector).First()
+		if root.Length() == 0 {
+			return nil, fmt.Errorf("schema selector %q not found", c.selector)
+		}
+	}
+	e := &extractor{base: htmlutil.PageBase(doc, pageURL), loc: c.loc}
+	return e.object("", root, c.fields)
+}
+
+// ExtractResponse 提取 kcrawl 的响应，url 类型的字段按响应的最终地址转成绝对地址
+func ExtractResponse(res *kcrawl.Response, schema *Schema) (map[string]any, error) {
+	return ExtractWithURL(string(res.Body), responseURL(res), schema)
+}
+
+// Decode 按 schema 提取 html 并解码到 v，v 的字段通过 json tag 和 schema 中的字段名对应，
+// time 类型的字段可以解码到 time.Time
+func Decode(html string, schema *Schema, v any) error {
+	return DecodeWithURL(html, "", schema, v)
+}
+
+// DecodeWithURL 和 Decode 一样，url 类型的字段按 pageURL 转成绝对地址
+func DecodeWithURL(html string, pageURL string, schema *Schema, v any) error {
+	m, err := ExtractWithURL(html, pageURL, schema)
+	if err != nil {
+		return err
+	}
+	return decode(m, v)
+}
+
+// DecodeResponse 提取 kcrawl 的响应并解码到 v
+func DecodeResponse(res *kcrawl.Response, schema *Schema, v any) error {
+	return DecodeWithURL(string(res.Body), responseURL(res), schema, v)
+}
+
+func responseURL(res *kcrawl.Response) string {
+	if res.URL == "" && res.Request != nil {
+		return res.Request.URL
+	}
+	return res.URL
+}
+
+func decode(m map[string]any, v any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode extracted fields: %w", err)
+	}
+	return nil
+}
+
+type extractor struct {
+	base *url.URL
+	loc  *time.Location
+}
+
+func (e *extractor) object(prefix string, root *goquery.Selection, fields []*compiledField) (map[string]any, error) {
+	obj := make(map[string]any, len(fields))
+	for _, f := range fields {
+		v, err := e.field(prefix+f.name, root, f)
+		if err != nil {
+			return nil, err
+		}
+		obj[f.name] = v
+	}
+	return obj, nil
+}
+
+func (e *extractor) field(path string, root *goquery.Selection, f *compiledField) (any, error) {
+	sel := root
+	if f.Selector != "" {
+		sel = root.Find(f.Selector)
+	}
+	if !f.List {
+		v, err := e.value(path, sel.First(), f)
+		if err == nil && v == nil && f.Required {
+			err = &FieldError{Path: path, Err: fmt.Errorf("no value for selector %q", f.Selector)}
+		}
+		return v, err
+	}
+	list := make([]any, 0, sel.Length())
+	for i := range sel.Nodes {
+		v, err := e.value(path+"."+strconv.Itoa(len(list)), sel.Eq(i), f)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 && f.Required {
+		return nil, &FieldError{Path: path, Err: fmt.Errorf("no value for selector %q", f.Selector)}
+	}
+	return list, nil
+}
+
+// value 提取一个结点的值，结点不存在或者没有值时返回 nil
+func (e *extractor) value(path string, s *goquery.Selection, f *compiledField) (any, error) {
+	if s.Length() == 0 {
+		return nil, nil
+	}
+	if len(f.fields) > 0 {
+		return e.object(path+".", s, f.fields)
+	}
+	var raw string
+	switch f.Attr {
+	case "":
+		raw = s.Text()
+	case "html":
+		h, err := s.Html()
+		if err != nil {
+			return nil, &FieldError{Path: path, Err: err}
+		}
+		raw = h
+	default:
+		v, ok := s.Attr(f.Attr)
+		if !ok {
+			return nil, nil
+		}
+		raw = v
+	}
+	// 文字和属性中连续的空白合并成一个空格，attr 为 html 时保持原样
+	if f.Attr != "html" {
+		raw = htmlutil.CollapseSpace(raw)
+	}
+	raw = strings.TrimSpace(raw)
+	if f.re != nil {
+		m := f.re.FindStringSubmatch(raw)
+		if m == nil {
+			return nil, nil
+		}
+		raw = m[0]
+		if len(m) > 1 {
+			raw = m[1]
+		}
+		raw = strings.TrimSpace(raw)
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := e.convert(raw, f)
+	if err != nil {
+		return nil, &FieldError{Path: path, Err: err}
+	}
+	return v, nil
+}
+
+func (e *extractor) convert(raw string, f *compiledField) (any, error) {
+	switch f.Type {
+	case TypeInt:
+		return strconv.ParseInt(numberText(raw), 10, 64)
+	case TypeFloat:
+		return strconv.ParseFloat(numberText(raw), 64)
+	case TypeBool:
+		return strconv.ParseBool(strings.ToLower(raw))
+	case TypeTime:
+		if f.Layout != "" {
+			return time.ParseInLocation(f.Layout, raw, e.loc)
+		}
+		t, ok := htmlutil.ParseTime(raw, e.loc)
+		if !ok {
+			return nil, fmt.Errorf("can't parse time %q", raw)
+		}
+		return t, nil
+	case TypeURL:
+		if e.base == nil {
+			return raw, nil
+		}
+		u, err := e.base.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		return u.String(), nil
+	}
+	return raw, nil
+}
+
+// numberText 去掉数字中的千分位和货币符号，如 "¥1,299.00" -> "1299.00"
+func numberText(s string) string {
+	s = strings.NewReplacer(",", "", "，", "", " ", "").Replace(s)
+	return strings.TrimLeft(s, "¥￥$€£")
+}
@@ -0,0 +1,170 @@
+package kschema
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+	"github.com/kevin-zx/kbase/kcrawl"
+)
+
+const testHTML = `<html><head><title>商品</title></head><body>
+<div class="nav"><h1>导航</h1></div>
+<div class="detail">
+  <h1> 华为 Mate 60
+  Pro </h1>
+  <span class="price">¥6,999.00</span>
+  <span class="sales">月销 1,234 件</span>
+  <span class="stock" data-instock="true">有货</span>
+  <div class="tags"><a href="/t/phone">手机</a><a href="/t/5g">5G</a></div>
+  <ul class="comments">
+    <li><span class="user">张三</span><time datetime="2024-03-01 10:30">3月1日</time><p>很好用</p></li>
+    <li><span class="user">李四</span><time datetime="2024/3/2">3月2日</time><p>一般</p></li>
+  </ul>
+</div>
+</body></html>`
+
+const testSchema = `
+selector: div.detail
+fields:
+  title:
+    selector: h1
+    required: true
+  price:
+    selector: .price
+    type: float
+  sales:
+    selector: .sales
+    regex: '月销\s*([\d,]+)'
+    type: int
+  in_stock:
+    selector: .stock
+    attr: data-instock
+    type: bool
+  tags:
+    selector: .tags a
+    list: true
+  tag_urls:
+    selector: .tags a
+    attr: href
+    type: url
+    list: true
+  missing:
+    selector: .none
+  comments:
+    selector: ul.comments > li
+    list: true
+    fields:
+      user: {selector: .user}
+      time: {selector: time, attr: datetime, type: time}
+`
+
+type testComment struct {
+	User string    `json:"user"`
+	Time time.Time `json:"time"`
+}
+
+type testProduct struct {
+	Title    string        `json:"title"`
+	Price    float64       `json:"price"`
+	Sales    int           `json:"sales"`
+	InStock  bool          `json:"in_stock"`
+	Tags     []string      `json:"tags"`
+	TagURLs  []string      `json:"tag_urls"`
+	Comments []testComment `json:"comments"`
+}
+
+func TestExtract(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ExtractWithURL(testHTML, "https://shop.example.com/p/1.html", schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["title"] != "华为 Mate 60 Pro" || m["price"] != 6999.0 || m["sales"] != int64(1234) || m["in_stock"] != true {
+		t.Fatalf("字段不对: %v", m)
+	}
+	if v, ok := m["missing"]; !ok || v != nil {
+		t.Fatalf("没有值的字段应该为 nil: %v", m)
+	}
+	urls := m["tag_urls"].([]any)
+	if len(urls) != 2 || urls[1] != "https://shop.example.com/t/5g" {
+		t.Fatalf("url 不对: %v", urls)
+	}
+	comments := m["comments"].([]any)
+	if len(comments) != 2 {
+		t.Fatalf("评论数量不对: %v", comments)
+	}
+	c := comments[0].(map[string]any)
+	want := time.Date(2024, 3, 1, 10, 30, 0, 0, htmlutil.DefaultLocation())
+	if c["user"] != "张三" || !c["time"].(time.Time).Equal(want) {
+		t.Fatalf("嵌套字段不对: %v", c)
+	}
+
+	var p testProduct
+	res := &kcrawl.Response{URL: "https://shop.example.com/p/1.html", Body: []byte(testHTML)}
+	if err = DecodeResponse(res, schema, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "华为 Mate 60 Pro" || p.Sales != 1234 || !p.InStock || len(p.Tags) != 2 || p.TagURLs[0] != "https://shop.example.com/t/phone" {
+		t.Fatalf("解码不对: %+v", p)
+	}
+	if len(p.Comments) != 2 || p.Comments[1].User != "李四" || !p.Comments[1].Time.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, htmlutil.DefaultLocation())) {
+		t.Fatalf("解码嵌套字段不对: %+v", p.Comments)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	_, err := ParseSchema([]byte(`{"fields": {"a": {"selector": "a", "type": "date"}}}`))
+	if err == nil {
+		t.Fatal("未知的类型应该返回错误")
+	}
+	_, err = ParseSchema([]byte(`{"fields": {"a": {"selector": "a", "regex": "("}}}`))
+	if err == nil {
+		t.Fatal("错误的正则应该返回错误")
+	}
+
+	schema, err := ParseSchema([]byte(`{"fields": {"list": {"selector": "li", "list": true, "fields": {"n": {"selector": "b", "type": "int"}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Extract(`<ul><li><b>1</b></li><li><b>x</b></li></ul>`, schema)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "list.1.n" {
+		t.Fatalf("应该返回字段路径: %v", err)
+	}
+
+	schema = &Schema{Fields: map[string]*Field{"title": {Selector: "h2", Required: true}}}
+	if _, err = Extract(testHTML, schema); !errors.As(err, &fe) || fe.Path != "title" {
+		t.Fatalf("必填字段没有值时应该返回错误: %v", err)
+	}
+}
+
+func TestExtractConcurrent(t *testing.T) {
+	// 直接构造的 Schema 没有提前 Compile，第一次使用时编译
+	schema := &Schema{Fields: map[string]*Field{
+		"price": {Selector: ".price", Regex: `([\d,.]+)`, Type: TypeFloat},
+		"time":  {Selector: "time", Attr: "datetime", Type: TypeTime},
+	}}
+	html := `<span class="price">¥6,999.00</span><time datetime="2024-03-01T10:30+09:00"></time>`
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := Extract(html, schema)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if m["price"] != 6999.0 || !m["time"].(time.Time).Equal(time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC)) {
+				t.Errorf("字段不对: %v", m)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,184 @@
+// 声明式的网页提取：用 YAML/JSON 描述字段对应的 css 选择器、属性、正则和类型，不再手写 goquery 代码
+package kschema
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kevin-zx/kbase/internal/htmlutil"
+	"gopkg.in/yaml.v3"
+)
+
+// 字段的类型
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+	TypeTime   = "time"
+	// 按页面地址转成绝对地址
+	TypeURL = "url"
+)
+
+var fieldTypes = []string{"", TypeString, TypeInt, TypeFloat, TypeBool, TypeTime, TypeURL}
+
+// Schema 提取的规则，例如:
+//
+//	selector: div.detail
+//	fields:
+//	  title:
+//	    selector: h1
+//	  price:
+//	    selector: .price
+//	    regex: '([\d.]+)'
+//	    type: float
+//	  tags:
+//	    selector: .tags a
+//	    list: true
+//	  comments:
+//	    selector: ul.comments > li
+//	    list: true
+//	    fields:
+//	      user: {selector: .user}
+//	      time: {selector: time, attr: datetime, type: time}
+type Schema struct {
+	// 不为空时所有字段都在第一个匹配的结点下查找
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// 没有时区的时间按这个时区解析，如 Asia/Shanghai，默认 Asia/Shanghai
+	Timezone string            `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	Fields   map[string]*Field `json:"fields" yaml:"fields"`
+
+	// Compile 的结果只生成一次，之后只读，可以在多个 goroutine 中使用
+	once     sync.Once
+	compiled *compiledSchema
+	err      error
+}
+
+// Field 一个字段的规则
+type Field struct {
+	// 为空时使用当前结点，用在嵌套的字段中
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// 取这个属性的值，为空时取文字，"html" 取内部的 html
+	Attr string `json:"attr,omitempty" yaml:"attr,omitempty"`
+	// 对取到的值做正则匹配，有分组时取第一个分组，否则取整个匹配，不匹配时当作没有值
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	// string（默认）、int、float、bool、time、url
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// time 类型的格式，如 2006-01-02，为空时自动识别常见的格式
+	Layout string `json:"layout,omitempty" yaml:"layout,omitempty"`
+	// 为 true 时取所有匹配的结点，结果为列表
+	List bool `json:"list,omitempty" yaml:"list,omitempty"`
+	// 为 true 时没有值返回错误
+	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
+	// 嵌套的字段，不为空时每个匹配的结点提取成一个 map
+	Fields map[string]*Field `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// compiledSchema 编译之后的规则，创建之后不再修改
+type compiledSchema struct {
+	selector string
+	loc      *time.Location
+	fields   []*compiledField
+}
+
+// compiledField 编译之后的字段，按名字排序
+type compiledField struct {
+	name string
+	// 规则的拷贝，不使用其中的 Fields
+	Field
+	re     *regexp.Regexp
+	fields []*compiledField
+}
+
+// ParseSchema 解析 YAML 或 JSON 格式的规则，并检查正则和类型是否正确
+func ParseSchema(data []byte) (*Schema, error) {
+	s := &Schema{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	if err := s.Compile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSchema 从文件读取规则
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(data)
+}
+
+// Compile 编译正则并检查类型。只会编译一次，之后修改 Schema 不会生效；Extract 时会自动调用，
+// 同一个 Schema 可以在多个 goroutine 中使用
+func (s *Schema) Compile() error {
+	s.once.Do(func() {
+		s.compiled, s.err = compileSchema(s)
+	})
+	return s.err
+}
+
+func compileSchema(s *Schema) (*compiledSchema, error) {
+	if len(s.Fields) == 0 {
+		return nil, fmt.Errorf("schema has no fields")
+	}
+	c := &compiledSchema{selector: s.Selector, loc: htmlutil.DefaultLocation()}
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("schema timezone %q: %w", s.Timezone, err)
+		}
+		c.loc = loc
+	}
+	fields, err := compileFields("", s.Fields)
+	if err != nil {
+		return nil, err
+	}
+	c.fields = fields
+	return c, nil
+}
+
+func compileFields(prefix string, fields map[string]*Field) ([]*compiledField, error) {
+	// 按名字排序，保证错误信息稳定
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	compiled := make([]*compiledField, 0, len(names))
+	for _, name := range names {
+		f := fields[name]
+		path := prefix + name
+		if f == nil {
+			return nil, fmt.Errorf("field %s: empty rule", path)
+		}
+		if !slices.Contains(fieldTypes, f.Type) {
+			return nil, fmt.Errorf("field %s: unknown type %q", path, f.Type)
+		}
+		if len(f.Fields) > 0 && (f.Type != "" || f.Regex != "" || f.Attr != "") {
+			return nil, fmt.Errorf("field %s: nested fields can't have type, regex or attr", path)
+		}
+		cf := &compiledField{name: name, Field: *f}
+		if f.Regex != "" {
+			re, err := regexp.Compile(f.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", path, err)
+			}
+			cf.re = re
+		}
+		nested, err := compileFields(path+".", f.Fields)
+		if err != nil {
+			return nil, err
+		}
+		cf.fields = nested
+		compiled = append(compiled, cf)
+	}
+	return compiled, nil
+}